@@ -1,16 +1,165 @@
 package config
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/jinzhu/configor"
 )
 
 // Config - Application configuration
 type Config struct {
-	Log    string `yaml:"log" default:"" env:"LOG_PATH"`
-	Debug  bool   `yaml:"debug" default:"false" env:"DEBUG"`
-	SQLite struct {
-		Path string `yaml:"path" default:"./sqlite.db" env:"SQLITE_PATH"`
-	} `yaml:"sqlite"`
+	Log       string `yaml:"log" default:"" env:"LOG_PATH"`
+	Debug     bool   `yaml:"debug" default:"false" env:"DEBUG"`
+	Transport struct {
+		// Type selects how the MCP server is exposed: stdio, sse, streamable-http or http (SSE and Streamable HTTP together)
+		Type string `yaml:"type" default:"stdio" env:"MCP_TRANSPORT"`
+		// Listen is a TCP address (host:port) or a Unix socket path prefixed with "unix:"
+		Listen          string        `yaml:"listen" default:"127.0.0.1:8080" env:"MCP_LISTEN"`
+		BaseURL         string        `yaml:"base_url" default:"" env:"MCP_BASE_URL"`
+		SSEPath         string        `yaml:"sse_path" default:"/sse"`
+		MessagePath     string        `yaml:"message_path" default:"/message"`
+		StreamablePath  string        `yaml:"streamable_path" default:"/mcp"`
+		ShutdownTimeout time.Duration `yaml:"shutdown_timeout" default:"10s"`
+	} `yaml:"transport"`
+	// SQLite is the database served as "default" when no databases are configured
+	SQLite Database `yaml:"sqlite"`
+	// Databases configures several named databases; tools select one with their database argument
+	Databases map[string]Database `yaml:"databases"`
+	// DefaultDatabase is the database used when a tool call does not name one
+	DefaultDatabase string `yaml:"default_database" env:"SQLITE_DEFAULT_DATABASE"`
+	Session         struct {
+		// IdleTimeout closes the connection pinned to a client session after this long without a call (-1 disables pinning)
+		IdleTimeout time.Duration `yaml:"idle_timeout" default:"10m" env:"SESSION_IDLE_TIMEOUT"`
+	} `yaml:"session"`
+	Transaction struct {
+		// AutoRollback rolls back a begin_transaction handle after this long without a call using it
+		AutoRollback time.Duration `yaml:"auto_rollback" default:"5m" env:"TRANSACTION_AUTO_ROLLBACK"`
+	} `yaml:"transaction"`
+	Export struct {
+		// ResourceTTL removes the resource registered for an exported file after this long (-1 keeps it for the client session)
+		ResourceTTL time.Duration `yaml:"resource_ttl" default:"1h" env:"EXPORT_RESOURCE_TTL"`
+		// MaxResourceBytes is the largest exported file served with resources/read (-1 disables the cap)
+		MaxResourceBytes int64 `yaml:"max_resource_bytes" default:"10485760" env:"EXPORT_MAX_RESOURCE_BYTES"`
+	} `yaml:"export"`
+	Query struct {
+		// MaxRows is the number of rows read_query returns per call unless the call passes limit
+		MaxRows int `yaml:"max_rows" default:"1000" env:"QUERY_MAX_ROWS"`
+		// MaxBytes caps the encoded size of the rows returned by one read_query call (-1 disables the cap)
+		MaxBytes int `yaml:"max_bytes" default:"1048576" env:"QUERY_MAX_BYTES"`
+		// DefaultFormat is the read_query output format used when a call does not pass one
+		DefaultFormat string `yaml:"default_format" default:"json_objects" env:"QUERY_DEFAULT_FORMAT"`
+		// Timeout interrupts the statements of a tool call that run longer (-1 disables)
+		Timeout time.Duration `yaml:"timeout" default:"30s" env:"QUERY_TIMEOUT"`
+		// MaxTimeout is the largest timeout_ms a call may request (-1 disables the cap)
+		MaxTimeout time.Duration `yaml:"max_timeout" default:"5m" env:"QUERY_MAX_TIMEOUT"`
+		Encoding   struct {
+			// Blob selects how BLOB values are encoded: base64 or hex
+			Blob string `yaml:"blob" default:"base64" env:"QUERY_BLOB_ENCODING"`
+			// LargeIntegers selects how integers beyond 2^53 are encoded: number or string
+			LargeIntegers string `yaml:"large_integers" default:"number" env:"QUERY_LARGE_INTEGERS"`
+			// TimeFormat is rfc3339, rfc3339nano, sqlite or a Go time layout
+			TimeFormat string `yaml:"time_format" default:"rfc3339" env:"QUERY_TIME_FORMAT"`
+			// TimeZone is UTC, Local or an IANA time zone name
+			TimeZone string `yaml:"time_zone" default:"UTC" env:"QUERY_TIME_ZONE"`
+		} `yaml:"encoding"`
+	} `yaml:"query"`
+	// Confirmation holds back destructive write_query statements until they are confirmed
+	Confirmation Confirmation `yaml:"confirmation"`
+}
+
+// Database - Location, open options and policy of one SQLite database
+type Database struct {
+	Path string `yaml:"path" default:"./sqlite.db" env:"SQLITE_PATH"`
+	// ReadOnly opens the database with mode=ro and denies writes with an authorizer
+	ReadOnly bool `yaml:"read_only" default:"false" env:"SQLITE_READ_ONLY"`
+	// MaxIdleConns is the number of idle connections kept open in the pool (-1 keeps none)
+	MaxIdleConns int `yaml:"max_idle_conns" default:"2" env:"SQLITE_MAX_IDLE_CONNS"`
+	// Options are added to the DSN as query parameters, e.g. _journal_mode: WAL or _busy_timeout: 5000
+	Options map[string]string `yaml:"options"`
+	// Limits are applied to every connection with sqlite3_limit; 0 keeps SQLite's compiled-in value
+	Limits struct {
+		// Length is the largest string or BLOB in bytes (SQLITE_LIMIT_LENGTH)
+		Length int `yaml:"length" env:"SQLITE_LIMIT_LENGTH"`
+		// SQLLength is the longest SQL statement in bytes (SQLITE_LIMIT_SQL_LENGTH)
+		SQLLength int `yaml:"sql_length" env:"SQLITE_LIMIT_SQL_LENGTH"`
+		// ExprDepth is the deepest expression tree (SQLITE_LIMIT_EXPR_DEPTH)
+		ExprDepth int `yaml:"expr_depth" env:"SQLITE_LIMIT_EXPR_DEPTH"`
+		// VDBEOps is the largest number of VM instructions one prepared program may contain (SQLITE_LIMIT_VDBE_OP);
+		// it bounds the size of a statement, not how long it runs
+		VDBEOps int `yaml:"vdbe_ops" env:"SQLITE_LIMIT_VDBE_OP"`
+		// VMSteps is the number of VM steps a statement may execute before it is interrupted, enforced with a
+		// progress handler instead of sqlite3_limit
+		VMSteps int `yaml:"vm_steps" env:"SQLITE_LIMIT_VM_STEPS"`
+	} `yaml:"limits"`
+	// Attach maps schema names to database files attached to every connection
+	Attach map[string]Attachment `yaml:"attach"`
+	// AttachDirs are the directories the attach_database tool may open files from; empty disables the tool
+	AttachDirs []string `yaml:"attach_dirs"`
+	// BackupDirs are the directories backups may be written to and restored from; empty disables backup and restore
+	BackupDirs []string `yaml:"backup_dirs"`
+	// ExportDirs are the directories export_query may write files to; empty disables the tool
+	ExportDirs []string `yaml:"export_dirs"`
+	// ImportDirs are the directories import_data may read files from; empty allows inline content only
+	ImportDirs []string `yaml:"import_dirs"`
+	// MigrationsDir holds the NNN_name.up.sql and .down.sql migrations of the database; empty disables migrations
+	MigrationsDir string `yaml:"migrations_dir" env:"SQLITE_MIGRATIONS_DIR"`
+}
+
+// Confirmation - Which write_query statements are held back until the call is repeated with a confirmation token
+type Confirmation struct {
+	// Enabled turns the confirmation of destructive statements on
+	Enabled bool `yaml:"enabled" default:"true" env:"CONFIRMATION_ENABLED"`
+	// MaxDeleteRows is the number of rows one DELETE may remove without confirmation (-1 disables the check)
+	MaxDeleteRows int `yaml:"max_delete_rows" default:"100" env:"CONFIRMATION_MAX_DELETE_ROWS"`
+	// ProtectedTables are tables, as name or schema.name, whose schema changes need confirmation
+	ProtectedTables []string `yaml:"protected_tables"`
+	// TokenTTL is how long a confirmation token stays valid
+	TokenTTL time.Duration `yaml:"token_ttl" default:"5m" env:"CONFIRMATION_TOKEN_TTL"`
+}
+
+// Attachment - A database file attached under a schema name
+type Attachment struct {
+	Path string `yaml:"path"`
+	// ReadOnly opens the file with mode=ro; attachments of a read-only database are always read-only
+	ReadOnly bool `yaml:"read_only"`
+}
+
+// DefaultDatabaseName - Name under which the sqlite section is served
+const DefaultDatabaseName = "default"
+
+// ResolveDatabases - The databases to open, keyed by name, and the name of the default one
+func (c *Config) ResolveDatabases() (map[string]Database, string, error) {
+	if len(c.Databases) == 0 {
+		if c.DefaultDatabase != "" && c.DefaultDatabase != DefaultDatabaseName {
+			return nil, "", fmt.Errorf("default_database %q is not configured", c.DefaultDatabase)
+		}
+		return map[string]Database{DefaultDatabaseName: c.SQLite}, DefaultDatabaseName, nil
+	}
+
+	for name, database := range c.Databases {
+		if name == "" {
+			return nil, "", fmt.Errorf("database names must not be empty")
+		}
+		if database.Path == "" {
+			return nil, "", fmt.Errorf("database %q has no path", name)
+		}
+	}
+
+	defaultName := c.DefaultDatabase
+	if defaultName == "" {
+		if len(c.Databases) > 1 {
+			return nil, "", fmt.Errorf("default_database is required when several databases are configured")
+		}
+		for name := range c.Databases {
+			defaultName = name
+		}
+	}
+	if _, ok := c.Databases[defaultName]; !ok {
+		return nil, "", fmt.Errorf("default_database %q is not configured", defaultName)
+	}
+
+	return c.Databases, defaultName, nil
 }
 
 // LoadConfig - Load configuration file
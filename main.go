@@ -7,6 +7,7 @@ import (
 	"github.com/cnosuke/mcp-sqlite/config"
 	"github.com/cnosuke/mcp-sqlite/logger"
 	"github.com/cnosuke/mcp-sqlite/server"
+	"github.com/cnosuke/mcp-sqlite/server/tools"
 	"github.com/cockroachdb/errors"
 	"github.com/urfave/cli/v2"
 )
@@ -21,6 +22,24 @@ var (
 	Usage = "A SQLite MCP server implementation"
 )
 
+// runMigrate - Load the configuration named by the migrate flags and run a migrate subcommand
+func runMigrate(c *cli.Context, opts server.MigrateOptions) error {
+	cfg, err := config.LoadConfig(c.String("config"))
+	if err != nil {
+		return errors.Wrap(err, "failed to load configuration file")
+	}
+
+	// Initialize logger
+	if err := logger.InitLogger(cfg.Debug, cfg.Log); err != nil {
+		return errors.Wrap(err, "failed to initialize logger")
+	}
+	defer logger.Sync()
+
+	opts.Database = c.String("database")
+	opts.Dir = c.String("dir")
+	return server.Migrate(cfg, opts, os.Stdout)
+}
+
 func main() {
 	app := cli.NewApp()
 	app.Version = fmt.Sprintf("%s (%s)", Version, Revision)
@@ -33,12 +52,22 @@ func main() {
 			Aliases: []string{"s"},
 			Usage:   "A simple MCP server implementation",
 			Flags: []cli.Flag{
-			&cli.StringFlag{
-			Name:    "config",
-			Aliases: []string{"c"},
-			Value:   "config.yml",
-			Usage:   "path to the configuration file",
-			},
+				&cli.StringFlag{
+					Name:    "config",
+					Aliases: []string{"c"},
+					Value:   "config.yml",
+					Usage:   "path to the configuration file",
+				},
+				&cli.StringFlag{
+					Name:    "transport",
+					Aliases: []string{"t"},
+					Usage:   "transport type (stdio, sse, streamable-http, http); overrides the configuration file",
+				},
+				&cli.StringFlag{
+					Name:    "listen",
+					Aliases: []string{"l"},
+					Usage:   "address to listen on for HTTP transports (host:port or unix:/path/to.sock); overrides the configuration file",
+				},
 			},
 			Action: func(c *cli.Context) error {
 				configPath := c.String("config")
@@ -49,6 +78,14 @@ func main() {
 					return errors.Wrap(err, "failed to load configuration file")
 				}
 
+				// Command-line flags take precedence over the configuration file
+				if c.IsSet("transport") {
+					cfg.Transport.Type = c.String("transport")
+				}
+				if c.IsSet("listen") {
+					cfg.Transport.Listen = c.String("listen")
+				}
+
 				// Initialize logger
 				if err := logger.InitLogger(cfg.Debug, cfg.Log); err != nil {
 					return errors.Wrap(err, "failed to initialize logger")
@@ -59,6 +96,133 @@ func main() {
 				return server.Run(cfg, Name, Version, Revision)
 			},
 		},
+		{
+			Name:      "backup",
+			Usage:     "Copy a live database to a file inside its backup_dirs",
+			ArgsUsage: "DEST",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "config",
+					Aliases: []string{"c"},
+					Value:   "config.yml",
+					Usage:   "path to the configuration file",
+				},
+				&cli.StringFlag{
+					Name:    "database",
+					Aliases: []string{"d"},
+					Usage:   "name of the configured database to back up; the default database when omitted",
+				},
+				&cli.StringFlag{
+					Name:    "method",
+					Aliases: []string{"m"},
+					Value:   tools.BackupOnline,
+					Usage:   "online (SQLite backup API, with progress) or vacuum (VACUUM INTO, compacted)",
+				},
+				&cli.BoolFlag{
+					Name:  "overwrite",
+					Usage: "replace an existing file at DEST",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return errors.New("backup expects the destination path as its only argument")
+				}
+
+				cfg, err := config.LoadConfig(c.String("config"))
+				if err != nil {
+					return errors.Wrap(err, "failed to load configuration file")
+				}
+
+				// Initialize logger
+				if err := logger.InitLogger(cfg.Debug, cfg.Log); err != nil {
+					return errors.Wrap(err, "failed to initialize logger")
+				}
+				defer logger.Sync()
+
+				return server.Backup(cfg, server.BackupOptions{
+					Database:  c.String("database"),
+					Path:      c.Args().First(),
+					Method:    c.String("method"),
+					Overwrite: c.Bool("overwrite"),
+				}, os.Stdout)
+			},
+		},
+		{
+			Name:  "migrate",
+			Usage: "Apply, roll back, inspect or create schema migrations",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "config",
+					Aliases: []string{"c"},
+					Value:   "config.yml",
+					Usage:   "path to the configuration file",
+				},
+				&cli.StringFlag{
+					Name:    "database",
+					Aliases: []string{"d"},
+					Usage:   "name of the configured database to migrate; the default database when omitted",
+				},
+				&cli.StringFlag{
+					Name:  "dir",
+					Usage: "migrations directory; overrides migrations_dir of the database",
+				},
+			},
+			Subcommands: []*cli.Command{
+				{
+					Name:  server.MigrateUp,
+					Usage: "Apply the pending migrations",
+					Flags: []cli.Flag{
+						&cli.Int64Flag{
+							Name:  "target",
+							Usage: "apply up to and including this version; all pending migrations when omitted",
+						},
+						&cli.BoolFlag{
+							Name:  "allow-out-of-order",
+							Usage: "also apply pending migrations numbered below the highest applied one",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						return runMigrate(c, server.MigrateOptions{
+							Command:         server.MigrateUp,
+							Target:          c.Int64("target"),
+							AllowOutOfOrder: c.Bool("allow-out-of-order"),
+						})
+					},
+				},
+				{
+					Name:  server.MigrateDown,
+					Usage: "Roll back the most recently applied migrations",
+					Flags: []cli.Flag{
+						&cli.IntFlag{
+							Name:  "steps",
+							Value: 1,
+							Usage: "number of migrations to roll back",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						return runMigrate(c, server.MigrateOptions{Command: server.MigrateDown, Steps: c.Int("steps")})
+					},
+				},
+				{
+					Name:  server.MigrateStatus,
+					Usage: "Show which migrations are applied, pending, out of order, modified or missing",
+					Action: func(c *cli.Context) error {
+						return runMigrate(c, server.MigrateOptions{Command: server.MigrateStatus})
+					},
+				},
+				{
+					Name:      server.MigrateCreate,
+					Usage:     "Create empty up and down files for a new migration",
+					ArgsUsage: "NAME",
+					Action: func(c *cli.Context) error {
+						if c.NArg() != 1 {
+							return errors.New("migrate create expects the migration name as its only argument")
+						}
+						return runMigrate(c, server.MigrateOptions{Command: server.MigrateCreate, Name: c.Args().First()})
+					},
+				},
+			},
+		},
 	}
 
 	if err := app.Run(os.Args); err != nil {
@@ -0,0 +1,415 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// TableName - Table recording the applied migrations
+const TableName = "schema_migrations"
+
+// Migration states reported by Status
+const (
+	StateApplied  = "applied"
+	StatePending  = "pending"
+	StateModified = "modified"
+	StateMissing  = "missing"
+	// StateOutOfOrder is a pending migration numbered below the highest applied one, e.g. from a merged branch
+	StateOutOfOrder = "out_of_order"
+)
+
+// fileNamePattern - NNN_name.up.sql or NNN_name.down.sql
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([^.]+)\.(up|down)\.sql$`)
+
+// Migration - One versioned migration read from the migrations directory
+type Migration struct {
+	Version int64
+	Name    string
+	// Up and Down are the SQL scripts; Down is empty when there is no .down.sql file
+	Up       string
+	Down     string
+	HasDown  bool
+	UpPath   string
+	DownPath string
+	// Checksum is the SHA-256 of the up script, recorded when the migration is applied
+	Checksum string
+}
+
+// MigrationStatus - A migration with its state in the database
+type MigrationStatus struct {
+	Version  int64  `json:"version"`
+	Name     string `json:"name"`
+	State    string `json:"state"`
+	Checksum string `json:"checksum"`
+	// AppliedChecksum is the checksum recorded when the migration was applied, if it differs
+	AppliedChecksum string `json:"applied_checksum,omitempty"`
+	AppliedAt       string `json:"applied_at,omitempty"`
+}
+
+// UpOptions - Which pending migrations Up applies
+type UpOptions struct {
+	// Target is the highest version applied; 0 applies all pending migrations
+	Target int64
+	// AllowOutOfOrder applies migrations numbered below the highest applied one instead of refusing them
+	AllowOutOfOrder bool
+}
+
+// Result - One migration applied or rolled back
+type Result struct {
+	Version  int64         `json:"version"`
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"-"`
+}
+
+// Load - Read the migrations of a directory in version order
+func Load(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s; expected NNN_name.up.sql or NNN_name.down.sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, m.Name, match[2])
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if match[3] == "up" {
+			m.Up, m.UpPath = string(content), path
+			m.Checksum = checksum(content)
+		} else {
+			m.Down, m.DownPath, m.HasDown = string(content), path, true
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.UpPath == "" {
+			return nil, fmt.Errorf("migration %d (%s) has no .up.sql file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// checksum - Fingerprint of a migration script
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Create - Write empty up and down files for a new migration, numbered after the existing ones
+func Create(dir, name string) (upPath, downPath string, err error) {
+	name = strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", fmt.Errorf("migration name must contain letters or digits")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", "", err
+	}
+	migrations, err := Load(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	var version int64 = 1
+	width := 3
+	if len(migrations) > 0 {
+		last := migrations[len(migrations)-1]
+		version = last.Version + 1
+		// Keep the zero padding of the existing files so that they sort by name
+		if digits := len(strings.SplitN(filepath.Base(last.UpPath), "_", 2)[0]); digits > width {
+			width = digits
+		}
+	}
+
+	base := fmt.Sprintf("%0*d_%s", width, version, name)
+	upPath = filepath.Join(dir, base+".up.sql")
+	downPath = filepath.Join(dir, base+".down.sql")
+	if err := os.WriteFile(upPath, []byte(fmt.Sprintf("-- %s: apply\n", base)), 0o644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(downPath, []byte(fmt.Sprintf("-- %s: revert\n", base)), 0o644); err != nil {
+		return "", "", err
+	}
+	return upPath, downPath, nil
+}
+
+// appliedMigration - A row of the schema_migrations table
+type appliedMigration struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt string
+}
+
+// ensureTable - Create the schema_migrations table if it does not exist yet
+func ensureTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS main.`+TableName+` (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	checksum TEXT NOT NULL,
+	applied_at TEXT NOT NULL
+)`)
+	return err
+}
+
+// applied - The applied migrations by version. A database without the table has none.
+func applied(ctx context.Context, conn *sql.Conn) (map[int64]appliedMigration, error) {
+	var exists int
+	if err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM main.sqlite_master WHERE type = 'table' AND name = ?", TableName).Scan(&exists); err != nil {
+		return nil, err
+	}
+	records := make(map[int64]appliedMigration)
+	if exists == 0 {
+		return records, nil
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM main."+TableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var record appliedMigration
+		if err := rows.Scan(&record.Version, &record.Name, &record.Checksum, &record.AppliedAt); err != nil {
+			return nil, err
+		}
+		records[record.Version] = record
+	}
+	return records, rows.Err()
+}
+
+// Status - The state of every migration, including applied ones whose files are gone, in version order
+func Status(ctx context.Context, conn *sql.Conn, migrations []Migration) ([]MigrationStatus, error) {
+	records, err := applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name, State: StatePending, Checksum: m.Checksum}
+		if record, ok := records[m.Version]; ok {
+			status.State = StateApplied
+			status.AppliedAt = record.AppliedAt
+			if record.Checksum != m.Checksum {
+				status.State = StateModified
+				status.AppliedChecksum = record.Checksum
+			}
+			delete(records, m.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, record := range records {
+		statuses = append(statuses, MigrationStatus{
+			Version:   record.Version,
+			Name:      record.Name,
+			State:     StateMissing,
+			Checksum:  record.Checksum,
+			AppliedAt: record.AppliedAt,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+
+	current := CurrentVersion(statuses)
+	for i := range statuses {
+		if statuses[i].State == StatePending && statuses[i].Version < current {
+			statuses[i].State = StateOutOfOrder
+		}
+	}
+	return statuses, nil
+}
+
+// CurrentVersion - The highest applied version, or 0
+func CurrentVersion(statuses []MigrationStatus) int64 {
+	var current int64
+	for _, status := range statuses {
+		if IsApplied(status.State) && status.Version > current {
+			current = status.Version
+		}
+	}
+	return current
+}
+
+// IsApplied - Check whether a state is one of a migration recorded in the database
+func IsApplied(state string) bool {
+	return state != StatePending && state != StateOutOfOrder
+}
+
+// checkDrift - Refuse to migrate while applied migration files were edited or removed
+func checkDrift(statuses []MigrationStatus) error {
+	for _, status := range statuses {
+		switch status.State {
+		case StateModified:
+			return fmt.Errorf("migration %d (%s) was edited after it was applied (checksum %s, applied %s)", status.Version, status.Name, status.Checksum, status.AppliedChecksum)
+		case StateMissing:
+			return fmt.Errorf("migration %d (%s) was applied but its file is missing", status.Version, status.Name)
+		}
+	}
+	return nil
+}
+
+// Up - Apply the pending migrations up to and including opts.Target, each in a transaction of its own.
+// Out-of-order migrations are refused unless opts allows them. The migrations applied before a failure stay applied.
+func Up(ctx context.Context, conn *sql.Conn, migrations []Migration, opts UpOptions) ([]Result, error) {
+	statuses, err := Status(ctx, conn, migrations)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDrift(statuses); err != nil {
+		return nil, err
+	}
+
+	current := CurrentVersion(statuses)
+	pending := make(map[int64]bool)
+	for _, status := range statuses {
+		if opts.Target > 0 && status.Version > opts.Target {
+			continue
+		}
+		switch status.State {
+		case StatePending:
+			pending[status.Version] = true
+		case StateOutOfOrder:
+			if !opts.AllowOutOfOrder {
+				return nil, fmt.Errorf("migration %d (%s) is numbered below the applied migration %d; allow out-of-order migrations to apply it", status.Version, status.Name, current)
+			}
+			pending[status.Version] = true
+		}
+	}
+	if err := ensureTable(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	for _, m := range migrations {
+		if !pending[m.Version] {
+			continue
+		}
+		started := time.Now()
+		err := inTransaction(ctx, conn, m.Up, func() error {
+			_, err := conn.ExecContext(ctx, "INSERT INTO main."+TableName+" (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)",
+				m.Version, m.Name, m.Checksum, time.Now().UTC().Format(time.RFC3339))
+			return err
+		})
+		if err != nil {
+			return results, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		results = append(results, Result{Version: m.Version, Name: m.Name, Duration: time.Since(started)})
+	}
+	return results, nil
+}
+
+// Down - Roll back the last steps applied migrations with their down scripts, newest first,
+// each in a transaction of its own
+func Down(ctx context.Context, conn *sql.Conn, migrations []Migration, steps int) ([]Result, error) {
+	statuses, err := Status(ctx, conn, migrations)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDrift(statuses); err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]Migration)
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+
+	var results []Result
+	for i := len(statuses) - 1; i >= 0 && len(results) < steps; i-- {
+		if statuses[i].State != StateApplied {
+			continue
+		}
+		m := byVersion[statuses[i].Version]
+		if !m.HasDown {
+			return results, fmt.Errorf("migration %d (%s) has no .down.sql file", m.Version, m.Name)
+		}
+		started := time.Now()
+		err := inTransaction(ctx, conn, m.Down, func() error {
+			_, err := conn.ExecContext(ctx, "DELETE FROM main."+TableName+" WHERE version = ?", m.Version)
+			return err
+		})
+		if err != nil {
+			return results, fmt.Errorf("rolling back migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		results = append(results, Result{Version: m.Version, Name: m.Name, Duration: time.Since(started)})
+	}
+	return results, nil
+}
+
+// inTransaction - Run a migration script and record the outcome inside BEGIN IMMEDIATE ... COMMIT,
+// rolling back when either fails
+func inTransaction(ctx context.Context, conn *sql.Conn, script string, record func() error) error {
+	if !autoCommit(conn) {
+		return fmt.Errorf("the connection is inside a transaction; migrations run in transactions of their own")
+	}
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return err
+	}
+
+	err := func() error {
+		if _, err := conn.ExecContext(ctx, script); err != nil {
+			return err
+		}
+		if autoCommit(conn) {
+			return fmt.Errorf("the script ended the transaction itself, so its changes were committed without recording the migration; remove BEGIN, COMMIT and ROLLBACK from the file")
+		}
+		if err := record(); err != nil {
+			return err
+		}
+		_, err := conn.ExecContext(ctx, "COMMIT")
+		return err
+	}()
+	// SQLite already rolled back when a statement was interrupted
+	if err != nil && !autoCommit(conn) {
+		conn.ExecContext(context.Background(), "ROLLBACK")
+	}
+	return err
+}
+
+// autoCommit - Check whether a connection is outside of any transaction
+func autoCommit(conn *sql.Conn) bool {
+	result := true
+	conn.Raw(func(driverConn any) error {
+		if sqliteConn, ok := driverConn.(*sqlite3.SQLiteConn); ok {
+			result = sqliteConn.AutoCommit()
+		}
+		return nil
+	})
+	return result
+}
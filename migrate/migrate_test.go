@@ -0,0 +1,288 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// writeFiles - Create a migrations directory holding the given files
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"010_add_index.up.sql":      "CREATE INDEX i ON users (email);",
+		"002_users.up.sql":          "CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT);",
+		"002_users.down.sql":        "DROP TABLE users;",
+		"1_init.up.sql":             "",
+		"README.md":                 "not a migration",
+		"notes.txt":                 "ignored",
+		"003_seed_data.up.sql.orig": "ignored",
+	})
+	if err := os.Mkdir(filepath.Join(dir, "004_dir.up.sql"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		version int64
+		name    string
+		hasDown bool
+	}{
+		{1, "init", false},
+		{2, "users", true},
+		{10, "add_index", false},
+	}
+	if len(migrations) != len(want) {
+		t.Fatalf("got %d migrations, want %d: %+v", len(migrations), len(want), migrations)
+	}
+	for i, w := range want {
+		m := migrations[i]
+		if m.Version != w.version || m.Name != w.name || m.HasDown != w.hasDown {
+			t.Errorf("migration %d = %d %s (down %v), want %d %s (down %v)", i, m.Version, m.Name, m.HasDown, w.version, w.name, w.hasDown)
+		}
+	}
+	if migrations[1].Down != "DROP TABLE users;" || migrations[1].DownPath != filepath.Join(dir, "002_users.down.sql") {
+		t.Errorf("down script of 002 = %q at %s", migrations[1].Down, migrations[1].DownPath)
+	}
+
+	// The checksum is the SHA-256 of the up script only
+	if got, want := migrations[0].Checksum, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"; got != want {
+		t.Errorf("checksum of an empty script = %s, want %s", got, want)
+	}
+	if migrations[1].Checksum != checksum([]byte("CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT);")) {
+		t.Errorf("checksum of 002 = %s does not match its up script", migrations[1].Checksum)
+	}
+}
+
+func TestLoadChecksumChanges(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"001_a.up.sql": "CREATE TABLE a (x);"})
+	before, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "001_a.up.sql"), []byte("CREATE TABLE a (x, y);"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	after, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before[0].Checksum == after[0].Checksum {
+		t.Error("checksum did not change with the up script")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name:    "name without version",
+			files:   map[string]string{"users.up.sql": ""},
+			wantErr: "invalid migration file name",
+		},
+		{
+			name:    "name without direction",
+			files:   map[string]string{"001_users.sql": ""},
+			wantErr: "invalid migration file name",
+		},
+		{
+			name:    "dot in the name",
+			files:   map[string]string{"001_users.v2.up.sql": ""},
+			wantErr: "invalid migration file name",
+		},
+		{
+			name:    "version beyond int64",
+			files:   map[string]string{"99999999999999999999_big.up.sql": ""},
+			wantErr: "invalid migration version",
+		},
+		{
+			name:    "version used twice",
+			files:   map[string]string{"001_a.up.sql": "", "1_b.up.sql": ""},
+			wantErr: "is used by both",
+		},
+		{
+			name:    "down without up",
+			files:   map[string]string{"001_a.down.sql": ""},
+			wantErr: "has no .up.sql file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeFiles(t, tt.files))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadMissingDirectory(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Load of a missing directory succeeded")
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"007_first.up.sql": ""})
+	upPath, downPath, err := Create(dir, "Add Users Table!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "008_add_users_table.up.sql"); upPath != want {
+		t.Errorf("up path = %s, want %s", upPath, want)
+	}
+	if want := filepath.Join(dir, "008_add_users_table.down.sql"); downPath != want {
+		t.Errorf("down path = %s, want %s", downPath, want)
+	}
+	if _, _, err := Create(dir, "!!!"); err == nil {
+		t.Error("Create with a name without letters or digits succeeded")
+	}
+}
+
+// openConn - A connection to a new in-memory database
+func openConn(t *testing.T) *sql.Conn {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// states - The state of each migration by version
+func states(t *testing.T, conn *sql.Conn, migrations []Migration) map[int64]string {
+	t.Helper()
+	statuses, err := Status(context.Background(), conn, migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[int64]string)
+	for _, status := range statuses {
+		got[status.Version] = status.State
+	}
+	return got
+}
+
+func TestUpOutOfOrder(t *testing.T) {
+	ctx := context.Background()
+	conn := openConn(t)
+	dir := writeFiles(t, map[string]string{
+		"001_a.up.sql": "CREATE TABLE a (x);",
+		"003_c.up.sql": "CREATE TABLE c (x);",
+	})
+	migrations, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Up(ctx, conn, migrations, UpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A migration from another branch arrives with a lower version than the applied 003
+	if err := os.WriteFile(filepath.Join(dir, "002_b.up.sql"), []byte("CREATE TABLE b (x);"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "004_d.up.sql"), []byte("CREATE TABLE d (x);"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if migrations, err = Load(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[int64]string{1: StateApplied, 2: StateOutOfOrder, 3: StateApplied, 4: StatePending}
+	got := states(t, conn, migrations)
+	for version, state := range want {
+		if got[version] != state {
+			t.Errorf("state of %d = %s, want %s", version, got[version], state)
+		}
+	}
+
+	results, err := Up(ctx, conn, migrations, UpOptions{})
+	if err == nil || !strings.Contains(err.Error(), "numbered below the applied migration 3") {
+		t.Fatalf("Up with an out-of-order migration = %v, want refused", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Up applied %+v before refusing", results)
+	}
+
+	// A target below the out-of-order migration does not reach it
+	if _, err := Up(ctx, conn, migrations, UpOptions{Target: 1}); err != nil {
+		t.Errorf("Up to version 1 = %v", err)
+	}
+
+	results, err = Up(ctx, conn, migrations, UpOptions{AllowOutOfOrder: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Version != 2 || results[1].Version != 4 {
+		t.Errorf("Up allowing out of order applied %+v, want 2 and 4", results)
+	}
+	for version, state := range states(t, conn, migrations) {
+		if state != StateApplied {
+			t.Errorf("state of %d = %s after Up, want applied", version, state)
+		}
+	}
+}
+
+func TestStatusDrift(t *testing.T) {
+	ctx := context.Background()
+	conn := openConn(t)
+	dir := writeFiles(t, map[string]string{
+		"001_a.up.sql": "CREATE TABLE a (x);",
+		"002_b.up.sql": "CREATE TABLE b (x);",
+	})
+	migrations, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Up(ctx, conn, migrations, UpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "001_a.up.sql"), []byte("CREATE TABLE a (x, y);"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "002_b.up.sql")); err != nil {
+		t.Fatal(err)
+	}
+	if migrations, err = Load(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	got := states(t, conn, migrations)
+	if got[1] != StateModified || got[2] != StateMissing {
+		t.Errorf("states = %v, want 1 modified and 2 missing", got)
+	}
+	if _, err := Up(ctx, conn, migrations, UpOptions{}); err == nil || !strings.Contains(err.Error(), "was edited after it was applied") {
+		t.Errorf("Up with an edited migration = %v, want refused", err)
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+)
+
+// readOnlyDeniedActions - Authorizer action codes that modify the database
+var readOnlyDeniedActions = map[int]string{
+	sqlite3.SQLITE_INSERT:              "INSERT",
+	sqlite3.SQLITE_UPDATE:              "UPDATE",
+	sqlite3.SQLITE_DELETE:              "DELETE",
+	sqlite3.SQLITE_CREATE_INDEX:        "CREATE INDEX",
+	sqlite3.SQLITE_CREATE_TABLE:        "CREATE TABLE",
+	sqlite3.SQLITE_CREATE_TEMP_INDEX:   "CREATE TEMP INDEX",
+	sqlite3.SQLITE_CREATE_TEMP_TABLE:   "CREATE TEMP TABLE",
+	sqlite3.SQLITE_CREATE_TEMP_TRIGGER: "CREATE TEMP TRIGGER",
+	sqlite3.SQLITE_CREATE_TEMP_VIEW:    "CREATE TEMP VIEW",
+	sqlite3.SQLITE_CREATE_TRIGGER:      "CREATE TRIGGER",
+	sqlite3.SQLITE_CREATE_VIEW:         "CREATE VIEW",
+	sqlite3.SQLITE_CREATE_VTABLE:       "CREATE VIRTUAL TABLE",
+	sqlite3.SQLITE_DROP_INDEX:          "DROP INDEX",
+	sqlite3.SQLITE_DROP_TABLE:          "DROP TABLE",
+	sqlite3.SQLITE_DROP_TEMP_INDEX:     "DROP TEMP INDEX",
+	sqlite3.SQLITE_DROP_TEMP_TABLE:     "DROP TEMP TABLE",
+	sqlite3.SQLITE_DROP_TEMP_TRIGGER:   "DROP TEMP TRIGGER",
+	sqlite3.SQLITE_DROP_TEMP_VIEW:      "DROP TEMP VIEW",
+	sqlite3.SQLITE_DROP_TRIGGER:        "DROP TRIGGER",
+	sqlite3.SQLITE_DROP_VIEW:           "DROP VIEW",
+	sqlite3.SQLITE_DROP_VTABLE:         "DROP VIRTUAL TABLE",
+	sqlite3.SQLITE_ALTER_TABLE:         "ALTER TABLE",
+	sqlite3.SQLITE_REINDEX:             "REINDEX",
+	sqlite3.SQLITE_ANALYZE:             "ANALYZE",
+	sqlite3.SQLITE_ATTACH:              "ATTACH",
+	sqlite3.SQLITE_DETACH:              "DETACH",
+	sqlite3.SQLITE_COPY:                "COPY",
+}
+
+// readOnlyDeniedFunctions - SQL functions with side effects outside of the query result
+var readOnlyDeniedFunctions = map[string]bool{
+	"writefile":      true,
+	"edit":           true,
+	"load_extension": true,
+}
+
+// readOnlyPragmasWithArgument - PRAGMAs that take an argument but only read information
+var readOnlyPragmasWithArgument = map[string]bool{
+	"table_info":        true,
+	"table_xinfo":       true,
+	"table_list":        true,
+	"index_list":        true,
+	"index_info":        true,
+	"index_xinfo":       true,
+	"foreign_key_list":  true,
+	"foreign_key_check": true,
+	"integrity_check":   true,
+	"quick_check":       true,
+}
+
+// readOnlyAuthorizer - Authorizer callback that denies every action which could modify a database
+func readOnlyAuthorizer(action int, arg1, arg2, dbName string) int {
+	if name, denied := readOnlyDeniedActions[action]; denied {
+		zap.S().Warnw("read-only authorizer denied action",
+			"action", name,
+			"target", arg1,
+			"database", dbName)
+		return sqlite3.SQLITE_DENY
+	}
+
+	switch action {
+	case sqlite3.SQLITE_FUNCTION:
+		// arg2 holds the function name
+		if readOnlyDeniedFunctions[strings.ToLower(arg2)] {
+			zap.S().Warnw("read-only authorizer denied function", "function", arg2)
+			return sqlite3.SQLITE_DENY
+		}
+	case sqlite3.SQLITE_PRAGMA:
+		// arg1 holds the pragma name and arg2 its argument; assignments such as
+		// "PRAGMA user_version = 1" change the database
+		if arg2 != "" && !readOnlyPragmasWithArgument[strings.ToLower(arg1)] {
+			zap.S().Warnw("read-only authorizer denied pragma",
+				"pragma", arg1,
+				"argument", arg2)
+			return sqlite3.SQLITE_DENY
+		}
+	}
+
+	return sqlite3.SQLITE_OK
+}
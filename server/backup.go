@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cnosuke/mcp-sqlite/config"
+	"github.com/cockroachdb/errors"
+	"go.uber.org/zap"
+)
+
+// BackupOptions - What the backup command copies and where to
+type BackupOptions struct {
+	// Database is the configured database to back up; "" selects the default one
+	Database string
+	// Path is the destination file inside one of the database's backup_dirs
+	Path      string
+	Method    string
+	Overwrite bool
+}
+
+// Backup - Copy one configured database to a file in its backup_dirs, reporting progress to out
+func Backup(cfg *config.Config, opts BackupOptions, out io.Writer) error {
+	sqliteServer, err := NewSQLiteServer(cfg)
+	if err != nil {
+		return err
+	}
+	defer sqliteServer.Close()
+
+	database, err := sqliteServer.Databases.Get(opts.Database)
+	if err != nil {
+		return err
+	}
+
+	// Report every tenth of the pages
+	lastTenth := -1
+	progress := func(copied, total int) {
+		tenth := 10
+		if total > 0 {
+			tenth = copied * 10 / total
+		}
+		if tenth != lastTenth {
+			lastTenth = tenth
+			fmt.Fprintf(out, "Copied %d of %d pages\n", copied, total)
+		}
+	}
+
+	zap.S().Infow("backing up database",
+		"database", database.Name,
+		"path", opts.Path,
+		"method", opts.Method)
+	result, err := database.Backup(context.Background(), opts.Path, opts.Method, opts.Overwrite, progress)
+	if err != nil {
+		zap.S().Errorw("failed to back up database", "database", database.Name, "error", err)
+		return errors.Wrapf(err, "failed to back up database %s", database.Name)
+	}
+	zap.S().Infow("backed up database",
+		"database", database.Name,
+		"path", result.Path,
+		"bytes", result.Bytes)
+
+	duration := time.Duration(result.DurationMS * float64(time.Millisecond)).Round(time.Millisecond)
+	fmt.Fprintf(out, "Backed up %s to %s (%d bytes) in %s\n", database.Name, result.Path, result.Bytes, duration)
+	return nil
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cnosuke/mcp-sqlite/config"
+	"github.com/cnosuke/mcp-sqlite/migrate"
+	"github.com/cockroachdb/errors"
+	"go.uber.org/zap"
+)
+
+// Migrate subcommands
+const (
+	MigrateUp     = "up"
+	MigrateDown   = "down"
+	MigrateStatus = "status"
+	MigrateCreate = "create"
+)
+
+// MigrateOptions - What the migrate command does and to which database
+type MigrateOptions struct {
+	Command string
+	// Database is the configured database to migrate; "" selects the default one
+	Database string
+	// Dir overrides the migrations_dir of the database
+	Dir string
+	// Target is the version up applies up to; 0 applies all pending migrations
+	Target int64
+	// AllowOutOfOrder lets up apply migrations numbered below the highest applied one
+	AllowOutOfOrder bool
+	// Steps is the number of migrations down rolls back
+	Steps int
+	// Name is the name of the migration create writes
+	Name string
+}
+
+// Migrate - Run a migrate subcommand against one configured database and report to out
+func Migrate(cfg *config.Config, opts MigrateOptions, out io.Writer) error {
+	configs, defaultName, err := cfg.ResolveDatabases()
+	if err != nil {
+		return errors.Wrap(err, "invalid database configuration")
+	}
+	name := opts.Database
+	if name == "" {
+		name = defaultName
+	}
+	dbCfg, ok := configs[name]
+	if !ok {
+		return fmt.Errorf("unknown database %q", name)
+	}
+	dir := opts.Dir
+	if dir == "" {
+		dir = dbCfg.MigrationsDir
+	}
+	if dir == "" {
+		return fmt.Errorf("database %s has no migrations_dir configured; pass --dir", name)
+	}
+
+	if opts.Command == MigrateCreate {
+		upPath, downPath, err := migrate.Create(dir, opts.Name)
+		if err != nil {
+			return errors.Wrap(err, "failed to create migration")
+		}
+		zap.S().Infow("created migration", "up", upPath, "down", downPath)
+		fmt.Fprintf(out, "Created %s\nCreated %s\n", upPath, downPath)
+		return nil
+	}
+
+	migrations, err := migrate.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	sqliteServer, err := NewSQLiteServer(cfg)
+	if err != nil {
+		return err
+	}
+	defer sqliteServer.Close()
+	database, err := sqliteServer.Databases.Get(name)
+	if err != nil {
+		return err
+	}
+	if opts.Command != MigrateStatus && database.ReadOnly {
+		return fmt.Errorf("database %s is read-only", name)
+	}
+
+	ctx := context.Background()
+	conn, release, err := database.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	var results []migrate.Result
+	switch opts.Command {
+	case MigrateUp:
+		results, err = migrate.Up(ctx, conn, migrations, migrate.UpOptions{Target: opts.Target, AllowOutOfOrder: opts.AllowOutOfOrder})
+	case MigrateDown:
+		results, err = migrate.Down(ctx, conn, migrations, opts.Steps)
+	case MigrateStatus:
+	default:
+		return fmt.Errorf("unknown migrate command %q", opts.Command)
+	}
+	for _, r := range results {
+		zap.S().Infow("migrated",
+			"database", name,
+			"command", opts.Command,
+			"version", r.Version,
+			"name", r.Name,
+			"duration", r.Duration)
+		verb := "Applied"
+		if opts.Command == MigrateDown {
+			verb = "Rolled back"
+		}
+		fmt.Fprintf(out, "%s migration %d (%s) in %s\n", verb, r.Version, r.Name, r.Duration.Round(time.Microsecond))
+	}
+	if err != nil {
+		zap.S().Errorw("migration failed", "database", name, "error", err)
+		return err
+	}
+	if opts.Command != MigrateStatus {
+		if len(results) == 0 {
+			fmt.Fprintln(out, "Nothing to do")
+		}
+		return nil
+	}
+
+	statuses, err := migrate.Status(ctx, conn, migrations)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Database %s, migrations in %s, current version %d\n", name, dir, migrate.CurrentVersion(statuses))
+	for _, status := range statuses {
+		fmt.Fprintf(out, "%-12s %6d  %s", status.State, status.Version, status.Name)
+		if status.AppliedAt != "" {
+			fmt.Fprintf(out, "  applied %s", status.AppliedAt)
+		}
+		fmt.Fprintln(out)
+	}
+	return nil
+}
@@ -39,6 +39,11 @@ func Run(cfg *config.Config, name string, version string, revision string) error
 			"error", err,
 		)
 	})
+	// Close the connections pinned to a session when the client disconnects
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		zap.S().Debugw("client session ended", "session_id", session.SessionID())
+		sqliteServer.Databases.CloseSession(session.SessionID())
+	})
 
 	// Create MCP server with server name and version
 	zap.S().Debugw("creating MCP server",
@@ -49,24 +54,35 @@ func Run(cfg *config.Config, name string, version string, revision string) error
 		name,
 		versionString,
 		server.WithHooks(hooks),
+		// export_query adds a resource for each file it exports on request
+		server.WithResourceCapabilities(false, true),
+		server.WithCompletions(),
+		server.WithResourceCompletionProvider(tools.NewResourceCompletionProvider(sqliteServer.Databases)),
 	)
 
 	// Register all tools
 	zap.S().Debug("registering tools")
-	if err := tools.RegisterAllTools(mcpServer, sqliteServer.DB); err != nil {
+	if err := tools.RegisterAllTools(mcpServer, sqliteServer.Databases, cfg); err != nil {
 		zap.S().Errorw("failed to register tools", "error", err)
 		return err
 	}
 
-	// Start the server with stdio transport
-	zap.S().Info("starting MCP server")
-	err = server.ServeStdio(mcpServer)
+	// Register schema resources
+	zap.S().Debug("registering resources")
+	if err := tools.RegisterAllResources(mcpServer, sqliteServer.Databases, cfg); err != nil {
+		zap.S().Errorw("failed to register resources", "error", err)
+		return err
+	}
+
+	// Start the server with the configured transport
+	zap.S().Infow("starting MCP server", "transport", cfg.Transport.Type)
+	err = serve(mcpServer, cfg)
 	if err != nil {
 		zap.S().Errorw("failed to start server", "error", err)
 		return errors.Wrap(err, "failed to start server")
 	}
 
-	// ServeStdio will block until the server is terminated
+	// serve will block until the server is terminated
 	zap.S().Info("server shutting down")
 	return nil
 }
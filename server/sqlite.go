@@ -1,51 +1,262 @@
 package server
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"net/url"
+	"sort"
+	"strings"
 
 	"github.com/cnosuke/mcp-sqlite/config"
+	"github.com/cnosuke/mcp-sqlite/server/tools"
 	"github.com/cockroachdb/errors"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 	"go.uber.org/zap"
 )
 
 // SQLiteServer - SQLite server structure
 type SQLiteServer struct {
-	DB  *sql.DB
-	cfg *config.Config
+	Databases *tools.Databases
+	cfg       *config.Config
 }
 
-// NewSQLiteServer - Create a new SQLite server
+// sqliteConnector - driver.Connector that opens connections with a configured SQLite driver
+type sqliteConnector struct {
+	driver *sqlite3.SQLiteDriver
+	dsn    string
+}
+
+// Connect - Open a new connection
+func (c *sqliteConnector) Connect(_ context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+// Driver - Return the underlying driver
+func (c *sqliteConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// NewSQLiteServer - Create a new SQLite server, opening every configured database
 func NewSQLiteServer(cfg *config.Config) (*SQLiteServer, error) {
-	zap.S().Infow("creating new SQLite server",
-		"database_path", cfg.SQLite.Path)
+	configs, defaultName, err := cfg.ResolveDatabases()
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid database configuration")
+	}
+
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var opened []*tools.Database
+	closeOpened := func() {
+		for _, database := range opened {
+			database.DB.Close()
+		}
+	}
+
+	for _, name := range names {
+		dbCfg := configs[name]
+		db, err := openDatabase(name, dbCfg)
+		if err != nil {
+			closeOpened()
+			return nil, err
+		}
+		database := &tools.Database{
+			Name:          name,
+			Path:          dbCfg.Path,
+			ReadOnly:      dbCfg.ReadOnly,
+			DB:            db,
+			AttachDirs:    dbCfg.AttachDirs,
+			BackupDirs:    dbCfg.BackupDirs,
+			ExportDirs:    dbCfg.ExportDirs,
+			ImportDirs:    dbCfg.ImportDirs,
+			MigrationsDir: dbCfg.MigrationsDir,
+			MaxIdleConns:  dbCfg.MaxIdleConns,
+			// Connections are pinned to client sessions unless the idle timeout is disabled
+			IdleTimeout: cfg.Session.IdleTimeout,
+		}
+		if dbCfg.ReadOnly {
+			database.Authorizer = readOnlyAuthorizer
+		}
+		opened = append(opened, database)
+
+		if err := attachDatabases(database, dbCfg); err != nil {
+			closeOpened()
+			return nil, err
+		}
+	}
 
-	db, err := sql.Open("sqlite3", cfg.SQLite.Path)
+	databases, err := tools.NewDatabases(defaultName, opened...)
 	if err != nil {
-		zap.S().Errorw("failed to open SQLite database",
-			"database_path", cfg.SQLite.Path,
-			"error", err)
-		return nil, errors.Wrap(err, "failed to open SQLite database")
+		closeOpened()
+		return nil, err
+	}
+
+	return &SQLiteServer{
+		Databases: databases,
+		cfg:       cfg,
+	}, nil
+}
+
+// openDatabase - Open one database and test the connection
+func openDatabase(name string, dbCfg config.Database) (*sql.DB, error) {
+	zap.S().Infow("opening SQLite database",
+		"database", name,
+		"database_path", dbCfg.Path,
+		"read_only", dbCfg.ReadOnly)
+
+	connector, err := newConnector(dbCfg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid configuration of database %s", name)
 	}
+	db := sql.OpenDB(connector)
+	db.SetMaxIdleConns(dbCfg.MaxIdleConns)
 
 	// Connection test
-	zap.S().Debug("testing database connection")
+	zap.S().Debugw("testing database connection", "database", name)
 	if err := db.Ping(); err != nil {
 		zap.S().Errorw("failed to connect to SQLite database",
-			"database_path", cfg.SQLite.Path,
+			"database", name,
+			"database_path", dbCfg.Path,
 			"error", err)
-		return nil, errors.Wrap(err, "failed to connect to SQLite database")
+		db.Close()
+		return nil, errors.Wrapf(err, "failed to connect to SQLite database %s", name)
 	}
-	zap.S().Info("successfully connected to SQLite database")
+	zap.S().Infow("successfully connected to SQLite database", "database", name)
 
-	return &SQLiteServer{
-		DB:  db,
-		cfg: cfg,
+	return db, nil
+}
+
+// attachDatabases - Attach the configured files and check that SQLite can open them
+func attachDatabases(database *tools.Database, dbCfg config.Database) error {
+	if len(dbCfg.Attach) == 0 {
+		return nil
+	}
+
+	for schema, attachment := range dbCfg.Attach {
+		zap.S().Infow("attaching database",
+			"database", database.Name,
+			"schema", schema,
+			"path", attachment.Path,
+			"read_only", attachment.ReadOnly)
+		if err := database.Attach(schema, attachment.Path, attachment.ReadOnly); err != nil {
+			return errors.Wrapf(err, "invalid attachment %s of database %s", schema, database.Name)
+		}
+	}
+
+	_, release, err := database.Conn(context.Background())
+	if err != nil {
+		zap.S().Errorw("failed to attach databases",
+			"database", database.Name,
+			"error", err)
+		return errors.Wrapf(err, "failed to attach databases to %s", database.Name)
+	}
+	release()
+	return nil
+}
+
+// newConnector - Build a connector whose connections follow the configured policy
+func newConnector(dbCfg config.Database) (*sqliteConnector, error) {
+	readOnly := dbCfg.ReadOnly
+	limits := connectionLimits(dbCfg)
+	steps := dbCfg.Limits.VMSteps
+	dsn, err := buildDSN(dbCfg.Path, readOnly, dbCfg.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqliteConnector{
+		driver: &sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				for id, value := range limits {
+					conn.SetLimit(id, value)
+				}
+				if steps > 0 {
+					setStepLimit(conn, steps)
+				}
+				if readOnly {
+					conn.RegisterAuthorizer(readOnlyAuthorizer)
+				}
+				return nil
+			},
+		},
+		dsn: dsn,
 	}, nil
 }
 
-// Close - Close the server
+// connectionLimits - Collect the configured sqlite3_limit values, skipping those left at 0
+func connectionLimits(dbCfg config.Database) map[int]int {
+	configured := map[int]int{
+		sqlite3.SQLITE_LIMIT_LENGTH:     dbCfg.Limits.Length,
+		sqlite3.SQLITE_LIMIT_SQL_LENGTH: dbCfg.Limits.SQLLength,
+		sqlite3.SQLITE_LIMIT_EXPR_DEPTH: dbCfg.Limits.ExprDepth,
+		sqlite3.SQLITE_LIMIT_VDBE_OP:    dbCfg.Limits.VDBEOps,
+	}
+
+	limits := make(map[int]int)
+	for id, value := range configured {
+		if value > 0 {
+			limits[id] = value
+		}
+	}
+	return limits
+}
+
+// buildDSN - Build the data source name from the path and open options, adding mode=ro in read-only mode.
+// Query parameters are only honoured in file: URIs, so a plain path is escaped into one; the parameters of a
+// path that already is a URI are merged with the options, which take precedence.
+func buildDSN(path string, readOnly bool, options map[string]string) (string, error) {
+	if len(options) == 0 && !readOnly {
+		return path, nil
+	}
+
+	var params url.Values
+	if strings.HasPrefix(path, "file:") {
+		base, query, _ := strings.Cut(path, "?")
+		existing, err := url.ParseQuery(query)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid query in database URI %s", path)
+		}
+		path, params = base, existing
+	} else {
+		if path == ":memory:" {
+			path = "file::memory:"
+		} else {
+			// Each segment is escaped on its own; SQLite decodes %HH, and a relative path stays relative
+			segments := strings.Split(path, "/")
+			for i, segment := range segments {
+				segments[i] = url.PathEscape(segment)
+			}
+			path = (&url.URL{Scheme: "file", Opaque: strings.Join(segments, "/")}).String()
+		}
+		params = url.Values{}
+	}
+
+	for key, value := range options {
+		params.Set(key, value)
+	}
+	if readOnly {
+		params.Set("mode", "ro")
+	}
+	return path + "?" + params.Encode(), nil
+}
+
+// Close - Close the server and all of its databases
 func (s *SQLiteServer) Close() error {
 	zap.S().Info("closing SQLite server")
-	return s.DB.Close()
+
+	s.Databases.RollbackTransactions()
+
+	var result error
+	for _, name := range s.Databases.Names() {
+		database, _ := s.Databases.Get(name)
+		database.CloseSessions()
+		if err := database.DB.Close(); err != nil {
+			result = errors.CombineErrors(result, errors.Wrapf(err, "failed to close database %s", name))
+		}
+	}
+	return result
 }
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnosuke/mcp-sqlite/config"
+)
+
+func TestBuildDSN(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		readOnly bool
+		options  map[string]string
+		want     string
+		wantErr  bool
+	}{
+		{name: "plain path without options", path: "./data/app.db", want: "./data/app.db"},
+		{name: "memory without options", path: ":memory:", want: ":memory:"},
+		{name: "read-only relative path", path: "./app.db", readOnly: true, want: "file:./app.db?mode=ro"},
+		{name: "read-only absolute path", path: "/srv/app.db", readOnly: true, want: "file:/srv/app.db?mode=ro"},
+		{
+			name:    "reserved characters in the path are escaped",
+			path:    "/srv/my data/a?b#c%d.db",
+			options: map[string]string{"_busy_timeout": "5000"},
+			want:    "file:/srv/my%20data/a%3Fb%23c%25d.db?_busy_timeout=5000",
+		},
+		{name: "memory with options", path: ":memory:", options: map[string]string{"cache": "shared"}, want: "file::memory:?cache=shared"},
+		{
+			name:     "uri query merged without duplicate keys",
+			path:     "file:app.db?mode=rwc&cache=shared",
+			readOnly: true,
+			options:  map[string]string{"cache": "private", "_journal_mode": "WAL"},
+			want:     "file:app.db?_journal_mode=WAL&cache=private&mode=ro",
+		},
+		{name: "uri without query", path: "file:app.db", options: map[string]string{"_fk": "1"}, want: "file:app.db?_fk=1"},
+		{name: "invalid uri query", path: "file:app.db?mode=%zz", readOnly: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildDSN(tt.path, tt.readOnly, tt.options)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("buildDSN = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("buildDSN = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenDatabaseEscapedPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "odd name?#%.db")
+	dbCfg := config.Database{Path: path, Options: map[string]string{"_busy_timeout": "1000"}}
+
+	db, err := openDatabase("test", dbCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE t (a)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("database was not created at %s: %v", path, err)
+	}
+}
@@ -0,0 +1,38 @@
+package server
+
+/*
+typedef struct sqlite3 sqlite3;
+
+// The symbol comes from the SQLite that go-sqlite3 links into the binary
+void sqlite3_progress_handler(sqlite3 *db, int ops, int (*handler)(void *), void *arg);
+
+// step_limit_reached - Progress handler that interrupts the statement it is called for
+static int step_limit_reached(void *arg) {
+	return 1;
+}
+
+// set_step_limit - Interrupt every statement of the connection once it has executed steps VM instructions
+static void set_step_limit(sqlite3 *db, int steps) {
+	sqlite3_progress_handler(db, steps, step_limit_reached, 0);
+}
+*/
+import "C"
+
+import (
+	"math"
+	"reflect"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// setStepLimit - Register a progress handler that interrupts any statement of the connection after it
+// has executed the given number of VM steps. SQLite counts the steps per statement, so the handler fires
+// for the first statement that reaches the budget; go-sqlite3 does not expose sqlite3_progress_handler,
+// so the handle is read from the connection.
+func setStepLimit(conn *sqlite3.SQLiteConn, steps int) {
+	if steps > math.MaxInt32 {
+		steps = math.MaxInt32
+	}
+	handle := reflect.ValueOf(conn).Elem().FieldByName("db").UnsafePointer()
+	C.set_step_limit((*C.sqlite3)(handle), C.int(steps))
+}
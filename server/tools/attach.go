@@ -0,0 +1,220 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+)
+
+// attachment - A database file attached to every connection under a schema name
+type attachment struct {
+	Schema   string `json:"schema"`
+	Path     string `json:"path"`
+	ReadOnly bool   `json:"read_only"`
+}
+
+// filename - The filename passed to ATTACH; read-only files are opened as a mode=ro URI
+func (a attachment) filename() string {
+	if !a.ReadOnly {
+		return a.Path
+	}
+	uri := url.URL{Scheme: "file", Path: a.Path, RawQuery: "mode=ro"}
+	return uri.String()
+}
+
+// Attach - Attach a database file to every connection under the schema name.
+// The path is trusted; attach_database checks it against AttachDirs first.
+func (d *Database) Attach(schema, path string, readOnly bool) error {
+	if schema == "" {
+		return fmt.Errorf("schema name must not be empty")
+	}
+	if strings.EqualFold(schema, "main") || strings.EqualFold(schema, "temp") {
+		return fmt.Errorf("schema name %s is reserved", schema)
+	}
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for name := range d.attachments {
+		if strings.EqualFold(name, schema) {
+			return fmt.Errorf("schema %s is already attached", name)
+		}
+	}
+	if d.attachments == nil {
+		d.attachments = make(map[string]attachment)
+	}
+	// A read-only database cannot write through its attachments either
+	d.attachments[schema] = attachment{Schema: schema, Path: resolved, ReadOnly: readOnly || d.ReadOnly}
+	return nil
+}
+
+// Detach - Stop attaching the schema to the connections of the database
+func (d *Database) Detach(schema string) (attachment, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for name, a := range d.attachments {
+		if strings.EqualFold(name, schema) {
+			delete(d.attachments, name)
+			return a, nil
+		}
+	}
+	return attachment{}, fmt.Errorf("schema %s is not attached", schema)
+}
+
+// Attachments - The attached databases sorted by schema name
+func (d *Database) Attachments() []attachment {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	attachments := make([]attachment, 0, len(d.attachments))
+	for _, a := range d.attachments {
+		attachments = append(attachments, a)
+	}
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].Schema < attachments[j].Schema })
+	return attachments
+}
+
+// syncAttachments - ATTACH and DETACH on one connection until its schemas match the attachment set
+func (d *Database) syncAttachments(ctx context.Context, conn *sql.Conn) error {
+	rows, err := conn.QueryContext(ctx, "SELECT name, file FROM pragma_database_list WHERE name NOT IN ('main', 'temp')")
+	if err != nil {
+		return err
+	}
+	current := make(map[string]string)
+	for rows.Next() {
+		var name, file string
+		if err := rows.Scan(&name, &file); err != nil {
+			rows.Close()
+			return err
+		}
+		current[name] = file
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	desired := d.Attachments()
+	var detach []string
+	var attach []attachment
+	for name, file := range current {
+		a, ok := findAttachment(desired, name)
+		if !ok || a.Path != file {
+			detach = append(detach, name)
+		}
+	}
+	for _, a := range desired {
+		if file, ok := current[a.Schema]; !ok || file != a.Path {
+			attach = append(attach, a)
+		}
+	}
+	if len(detach) == 0 && len(attach) == 0 {
+		return nil
+	}
+
+	return conn.Raw(func(driverConn any) error {
+		sqliteConn, ok := driverConn.(*sqlite3.SQLiteConn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection type %T", driverConn)
+		}
+
+		// The read-only authorizer denies ATTACH and DETACH, which would otherwise
+		// keep attach_database from working on read-only databases
+		if d.Authorizer != nil {
+			sqliteConn.RegisterAuthorizer(nil)
+			defer sqliteConn.RegisterAuthorizer(d.Authorizer)
+		}
+
+		for _, name := range detach {
+			zap.S().Debugw("detaching database from connection", "database", d.Name, "schema", name)
+			if _, err := sqliteConn.Exec("DETACH DATABASE ?", []driver.Value{name}); err != nil {
+				return fmt.Errorf("failed to detach %s: %w", name, err)
+			}
+		}
+		for _, a := range attach {
+			zap.S().Debugw("attaching database to connection", "database", d.Name, "schema", a.Schema, "path", a.Path, "read_only", a.ReadOnly)
+			if _, err := sqliteConn.Exec("ATTACH DATABASE ? AS ?", []driver.Value{a.filename(), a.Schema}); err != nil {
+				return fmt.Errorf("failed to attach %s as %s: %w", a.Path, a.Schema, err)
+			}
+		}
+		return nil
+	})
+}
+
+// findAttachment - Find the attachment with the schema name in a list
+func findAttachment(attachments []attachment, schema string) (attachment, bool) {
+	for _, a := range attachments {
+		if a.Schema == schema {
+			return a, true
+		}
+	}
+	return attachment{}, false
+}
+
+// allowedAttachPath - Resolve a path given to attach_database and check that it lies in one of the AttachDirs
+func (d *Database) allowedAttachPath(path string) (string, error) {
+	if len(d.AttachDirs) == 0 {
+		return "", fmt.Errorf("attaching databases is disabled for %s; configure attach_dirs to allow it", d.Name)
+	}
+	if path == "" || path == ":memory:" || strings.HasPrefix(path, "file:") {
+		return "", fmt.Errorf("path must be a file path, got %q", path)
+	}
+
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if !pathInDirs(resolved, d.AttachDirs) {
+		return "", fmt.Errorf("path %s is outside the allowed directories %v", path, d.AttachDirs)
+	}
+	return resolved, nil
+}
+
+// pathInDirs - Check whether a resolved path lies below one of the directories
+func pathInDirs(resolved string, dirs []string) bool {
+	for _, dir := range dirs {
+		root, err := resolvePath(dir)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(root, resolved)
+		if err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePath - Absolute path with symlinks resolved; a file that does not exist yet is resolved through its directory
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err == nil {
+		return resolved, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(abs))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(abs)), nil
+}
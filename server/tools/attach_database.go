@@ -0,0 +1,164 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/cnosuke/mcp-sqlite/config"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// AttachDatabaseArgs - Arguments for attach_database tool (kept for testing compatibility)
+type AttachDatabaseArgs struct {
+	Schema   string `json:"schema" jsonschema:"description=Schema name under which the file is attached, used as schema.table in queries"`
+	Path     string `json:"path" jsonschema:"description=Path of the database file inside one of the allowed directories"`
+	ReadOnly bool   `json:"read_only,omitempty" jsonschema:"description=Open the file read-only"`
+}
+
+// DetachDatabaseArgs - Arguments for detach_database tool (kept for testing compatibility)
+type DetachDatabaseArgs struct {
+	Schema string `json:"schema" jsonschema:"description=Schema name of the attached database"`
+}
+
+// attachResult - Response body of attach_database and detach_database
+type attachResult struct {
+	Database string `json:"database"`
+	attachment
+	Attached bool `json:"attached"`
+}
+
+// RegisterAttachDatabaseTools - Register the attach_database and detach_database tools
+func RegisterAttachDatabaseTools(mcpServer *server.MCPServer, databases *Databases, cfg *config.Config) error {
+	zap.S().Debug("registering attach_database and detach_database tools")
+
+	// Define the attach tool
+	attachTool := mcp.NewTool("attach_database",
+		mcp.WithDescription("Attach another SQLite file to every connection of a database so that queries can join across files as schema.table. "+
+			"Only files inside the configured attach_dirs can be attached; list_databases shows them"),
+		mcp.WithString("schema",
+			mcp.Description("Schema name under which the file is attached, used as schema.table in queries"),
+			mcp.Required(),
+		),
+		mcp.WithString("path",
+			mcp.Description("Path of the database file inside one of the allowed directories. A missing file is created unless read_only is set"),
+			mcp.Required(),
+		),
+		mcp.WithBoolean("read_only",
+			mcp.Description("Open the file read-only (always the case for read-only databases)"),
+		),
+		mcp.WithNumber("timeout_ms",
+			mcp.Description(timeoutDescription),
+			mcp.Min(1),
+		),
+		databaseArgument(databases),
+	)
+
+	mcpServer.AddTool(attachTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		schema := request.GetString("schema", "")
+		path := request.GetString("path", "")
+		readOnly := request.GetBool("read_only", false)
+		if schema == "" {
+			return mcp.NewToolResultError("schema parameter is required"), nil
+		}
+		if path == "" {
+			return mcp.NewToolResultError("path parameter is required"), nil
+		}
+
+		database, err := requestDatabase(databases, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		timeout, err := callTimeout(cfg, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx, cancel := withCallTimeout(ctx, timeout)
+		defer cancel()
+
+		zap.S().Debugw("executing attach_database",
+			"database", database.Name,
+			"schema", schema,
+			"path", path,
+			"read_only", readOnly)
+
+		resolved, err := database.allowedAttachPath(path)
+		if err != nil {
+			zap.S().Warnw("rejected attach path", "path", path, "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if err := database.Attach(schema, resolved, readOnly); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		// Attach on one connection right away so that a file SQLite cannot open is reported here
+		_, release, err := database.Conn(ctx)
+		if err != nil {
+			zap.S().Errorw("failed to attach database",
+				"schema", schema,
+				"path", resolved,
+				"error", err)
+			database.Detach(schema)
+			return errorResult(ctx, timeout, err), nil
+		}
+		release()
+
+		attached, _ := findAttachment(database.Attachments(), schema)
+		zap.S().Infow("attached database",
+			"database", database.Name,
+			"schema", schema,
+			"path", attached.Path,
+			"read_only", attached.ReadOnly)
+
+		result, err := jsonToolResult(attachResult{Database: database.Name, attachment: attached, Attached: true})
+		if err != nil {
+			zap.S().Errorw("failed to convert result to JSON", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return result, nil
+	})
+
+	// Define the detach tool
+	detachTool := mcp.NewTool("detach_database",
+		mcp.WithDescription("Detach a database attached by attach_database or by the configuration from every connection"),
+		mcp.WithString("schema",
+			mcp.Description("Schema name of the attached database"),
+			mcp.Required(),
+		),
+		databaseArgument(databases),
+	)
+
+	mcpServer.AddTool(detachTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		schema := request.GetString("schema", "")
+		if schema == "" {
+			return mcp.NewToolResultError("schema parameter is required"), nil
+		}
+
+		database, err := requestDatabase(databases, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		zap.S().Debugw("executing detach_database", "database", database.Name, "schema", schema)
+
+		// Connections drop the schema the next time a tool takes them
+		detached, err := database.Detach(schema)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		zap.S().Infow("detached database",
+			"database", database.Name,
+			"schema", detached.Schema,
+			"path", detached.Path)
+
+		result, err := jsonToolResult(attachResult{Database: database.Name, attachment: detached, Attached: false})
+		if err != nil {
+			zap.S().Errorw("failed to convert result to JSON", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return result, nil
+	})
+
+	return nil
+}
@@ -0,0 +1,312 @@
+package tools
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+)
+
+// Backup methods accepted by backup_database and the backup command
+const (
+	// BackupOnline copies the pages with SQLite's online backup API, in steps that let other connections write in between
+	BackupOnline = "online"
+	// BackupVacuum writes a compacted copy with VACUUM INTO in one statement
+	BackupVacuum = "vacuum"
+)
+
+// BackupMethods - All supported backup methods
+var BackupMethods = []string{BackupOnline, BackupVacuum}
+
+// backupStepPages - Pages copied per step of the online backup API
+const backupStepPages = 256
+
+// defaultMaxIdleConns - database/sql's default number of idle connections kept in the pool, used when
+// Database.MaxIdleConns is not set
+const defaultMaxIdleConns = 2
+
+// backupRetryDelay - Wait before retrying a backup step that found the database locked
+const backupRetryDelay = 10 * time.Millisecond
+
+// BackupProgress - Reports the pages copied so far out of the total after each step
+type BackupProgress func(copied, total int)
+
+// BackupResult - Outcome of a backup or restore
+type BackupResult struct {
+	Database string `json:"database"`
+	Path     string `json:"path"`
+	Method   string `json:"method,omitempty"`
+	// Pages is the number of pages copied by the online backup API
+	Pages      int     `json:"pages,omitempty"`
+	Bytes      int64   `json:"bytes"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// allowedBackupPath - Resolve a backup destination or restore source and check that it lies in one of the BackupDirs
+func (d *Database) allowedBackupPath(path string) (string, error) {
+	if len(d.BackupDirs) == 0 {
+		return "", fmt.Errorf("backups are disabled for %s; configure backup_dirs to allow them", d.Name)
+	}
+	if path == "" || path == ":memory:" || strings.HasPrefix(path, "file:") {
+		return "", fmt.Errorf("path must be a file path, got %q", path)
+	}
+
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if !pathInDirs(resolved, d.BackupDirs) {
+		return "", fmt.Errorf("path %s is outside the allowed directories %v", path, d.BackupDirs)
+	}
+	if database, err := resolvePath(d.Path); err == nil && database == resolved {
+		return "", fmt.Errorf("path %s is the database file itself", path)
+	}
+	return resolved, nil
+}
+
+// Backup - Copy the live database to a file in one of the BackupDirs. The copy is written to a
+// temporary file next to the destination and renamed into place once it is complete.
+func (d *Database) Backup(ctx context.Context, path, method string, overwrite bool, progress BackupProgress) (*BackupResult, error) {
+	resolved, err := d.allowedBackupPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(resolved); err == nil && !overwrite {
+		return nil, fmt.Errorf("%s already exists; pass overwrite to replace it", path)
+	}
+	temp, err := tempPathFor(resolved)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(temp)
+
+	started := time.Now()
+	result := &BackupResult{Database: d.Name, Path: resolved, Method: method}
+
+	conn, release, err := d.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	switch method {
+	case BackupOnline:
+		err = conn.Raw(func(driverConn any) error {
+			source, ok := driverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected driver connection type %T", driverConn)
+			}
+			dest, err := openSQLiteFile(temp, false)
+			if err != nil {
+				return err
+			}
+			defer dest.Close()
+			pages, err := copyPages(ctx, dest, source, progress)
+			result.Pages = pages
+			return err
+		})
+	case BackupVacuum:
+		err = conn.Raw(func(driverConn any) error {
+			sqliteConn, ok := driverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected driver connection type %T", driverConn)
+			}
+			// VACUUM INTO attaches the destination internally, which the read-only authorizer denies
+			if d.Authorizer != nil {
+				sqliteConn.RegisterAuthorizer(nil)
+				defer sqliteConn.RegisterAuthorizer(d.Authorizer)
+			}
+			_, err := sqliteConn.ExecContext(ctx, "VACUUM main INTO ?", []driver.NamedValue{{Ordinal: 1, Value: temp}})
+			return err
+		})
+	default:
+		return nil, fmt.Errorf("unknown backup method %q; expected one of %v", method, BackupMethods)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(temp, resolved); err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(resolved); err == nil {
+		result.Bytes = info.Size()
+	}
+	result.DurationMS = float64(time.Since(started).Microseconds()) / 1000
+	return result, nil
+}
+
+// tempPathFor - A hidden, unique file next to path that is renamed to path once it is complete
+func tempPathFor(path string) (string, error) {
+	suffix, err := newHandle("")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+"."+suffix+".tmp"), nil
+}
+
+// openSQLiteFile - Open a connection to a database file outside of the configured databases
+func openSQLiteFile(path string, readOnly bool) (*sqlite3.SQLiteConn, error) {
+	// Opened as a file: URI with each segment escaped, so that a ? or # in the path is not taken
+	// for parameters; SQLite decodes %HH, and a relative path stays relative
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	uri := &url.URL{Scheme: "file", Opaque: strings.Join(segments, "/")}
+	if readOnly {
+		uri.RawQuery = "mode=ro"
+	}
+	name := uri.String()
+	conn, err := (&sqlite3.SQLiteDriver{}).Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*sqlite3.SQLiteConn), nil
+}
+
+// copyPages - Copy the main database of source into dest with the online backup API, reporting progress after each step.
+// A step that finds the source or destination locked is retried.
+func copyPages(ctx context.Context, dest, source *sqlite3.SQLiteConn, progress BackupProgress) (int, error) {
+	backup, err := dest.Backup("main", source, "main")
+	if err != nil {
+		return 0, err
+	}
+	defer backup.Close()
+
+	remaining := -1
+	for {
+		done, err := backup.Step(backupStepPages)
+		if err != nil {
+			return 0, err
+		}
+		total := backup.PageCount()
+		if progress != nil && backup.Remaining() != remaining {
+			progress(total-backup.Remaining(), total)
+		}
+		if done {
+			return total, backup.Finish()
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if backup.Remaining() == remaining {
+			time.Sleep(backupRetryDelay)
+		}
+		remaining = backup.Remaining()
+	}
+}
+
+// integrityCheck - Run PRAGMA integrity_check on a database file and return the problems it reports
+func integrityCheck(ctx context.Context, conn *sqlite3.SQLiteConn) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, "PRAGMA integrity_check", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var problems []string
+	values := make([]driver.Value, 1)
+	for {
+		if err := rows.Next(values); err == io.EOF {
+			return problems, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if message := fmt.Sprint(values[0]); message != "ok" {
+			problems = append(problems, message)
+		}
+	}
+}
+
+// Restore - Replace the contents of a database with a backup file from its BackupDirs after checking the
+// file with PRAGMA integrity_check. Open transactions on the database are rolled back and the connections
+// pinned to sessions closed first; idle pooled connections are closed afterwards, so every later call
+// starts on a freshly opened connection.
+func (d *Databases) Restore(ctx context.Context, database *Database, path string, progress BackupProgress) (*BackupResult, error) {
+	source, resolved, err := database.openRestoreSource(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
+	started := time.Now()
+	d.rollbackTransactions(func(t *transaction) bool { return t.Database == database }, "rolled back because the database was restored")
+	database.CloseSessions()
+
+	conn, err := database.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &BackupResult{Database: database.Name, Path: resolved}
+	err = conn.Raw(func(driverConn any) error {
+		dest, ok := driverConn.(*sqlite3.SQLiteConn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection type %T", driverConn)
+		}
+		pages, err := copyPages(ctx, dest, source, progress)
+		result.Pages = pages
+		return err
+	})
+	// The connection that did the restore is not reused either
+	discardConn(conn)
+	if err != nil {
+		return nil, err
+	}
+	database.closeConns()
+
+	if info, err := os.Stat(resolved); err == nil {
+		result.Bytes = info.Size()
+	}
+	result.DurationMS = float64(time.Since(started).Microseconds()) / 1000
+	return result, nil
+}
+
+// openRestoreSource - Open a restore source read-only and check its integrity
+func (d *Database) openRestoreSource(ctx context.Context, path string) (*sqlite3.SQLiteConn, string, error) {
+	resolved, err := d.allowedBackupPath(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := os.Stat(resolved); err != nil {
+		return nil, "", err
+	}
+
+	source, err := openSQLiteFile(resolved, true)
+	if err != nil {
+		return nil, "", err
+	}
+	problems, err := integrityCheck(ctx, source)
+	if err == nil && len(problems) > 0 {
+		err = fmt.Errorf("%s failed the integrity check: %s", path, strings.Join(problems, "; "))
+	}
+	if err != nil {
+		source.Close()
+		return nil, "", err
+	}
+	return source, resolved, nil
+}
+
+// closeConns - Make later calls start on new connections once the database was restored. Connections
+// taken out of the pool before are closed when released, sessions pinned while the restore ran are closed,
+// and the idle connections are closed before the configured idle limit is set again.
+func (d *Database) closeConns() {
+	d.generation.Add(1)
+	d.CloseSessions()
+
+	maxIdle := d.MaxIdleConns
+	if maxIdle == 0 {
+		maxIdle = defaultMaxIdleConns
+	}
+	d.DB.SetMaxIdleConns(0)
+	d.DB.SetMaxIdleConns(maxIdle)
+	zap.S().Debugw("closed connections opened before the restore", "database", d.Name)
+}
@@ -0,0 +1,287 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cnosuke/mcp-sqlite/config"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// BackupDatabaseArgs - Arguments for backup_database tool (kept for testing compatibility)
+type BackupDatabaseArgs struct {
+	Path      string `json:"path" jsonschema:"description=Destination file inside one of the allowed backup directories"`
+	Method    string `json:"method,omitempty" jsonschema:"description=online (backup API) or vacuum (VACUUM INTO)"`
+	Overwrite bool   `json:"overwrite,omitempty" jsonschema:"description=Replace an existing file at path"`
+	TimeoutMS int    `json:"timeout_ms,omitempty" jsonschema:"description=Timeout in milliseconds"`
+	Database  string `json:"database,omitempty" jsonschema:"description=Name of the database to use"`
+}
+
+// RestoreDatabaseArgs - Arguments for restore_database tool (kept for testing compatibility)
+type RestoreDatabaseArgs struct {
+	Path              string `json:"path" jsonschema:"description=Backup file inside one of the allowed backup directories"`
+	ConfirmationToken string `json:"confirmation_token,omitempty" jsonschema:"description=Token returned when the restore was held back; repeat the same call with it to restore"`
+	TimeoutMS         int    `json:"timeout_ms,omitempty" jsonschema:"description=Timeout in milliseconds"`
+	Database          string `json:"database,omitempty" jsonschema:"description=Name of the database to use"`
+}
+
+// restorePreview - What a restore held back for confirmation would replace
+type restorePreview struct {
+	Source      string `json:"source"`
+	SourceBytes int64  `json:"source_bytes"`
+	Integrity   string `json:"integrity"`
+	Database    string `json:"database"`
+	Path        string `json:"path"`
+	Bytes       int64  `json:"bytes"`
+	// OpenTransactions are rolled back by the restore
+	OpenTransactions int `json:"open_transactions"`
+}
+
+// progressNotifier - Report backup progress to a client that passed a progress token, once per percent
+func progressNotifier(ctx context.Context, request mcp.CallToolRequest) BackupProgress {
+	if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return nil
+	}
+	mcpServer := server.ServerFromContext(ctx)
+	if mcpServer == nil {
+		return nil
+	}
+	token := request.Params.Meta.ProgressToken
+
+	lastPercent := -1
+	return func(copied, total int) {
+		percent := 100
+		if total > 0 {
+			percent = copied * 100 / total
+		}
+		if percent == lastPercent {
+			return
+		}
+		lastPercent = percent
+		err := mcpServer.SendNotificationToClient(ctx, "notifications/progress", map[string]any{
+			"progressToken": token,
+			"progress":      copied,
+			"total":         total,
+			"message":       fmt.Sprintf("copied %d of %d pages", copied, total),
+		})
+		if err != nil {
+			zap.S().Debugw("failed to send progress notification", "error", err)
+		}
+	}
+}
+
+// openTransactions - Number of open transaction handles on a database
+func (d *Databases) openTransactions(database *Database) int {
+	d.transactions.mu.Lock()
+	defer d.transactions.mu.Unlock()
+	count := 0
+	for _, t := range d.transactions.byID {
+		if t.Database == database {
+			count++
+		}
+	}
+	return count
+}
+
+// previewRestore - Check the restore source and describe what the restore would replace
+func (d *Databases) previewRestore(ctx context.Context, database *Database, path string) (restorePreview, error) {
+	source, resolved, err := database.openRestoreSource(ctx, path)
+	if err != nil {
+		return restorePreview{}, err
+	}
+	source.Close()
+
+	preview := restorePreview{
+		Source:           resolved,
+		Integrity:        "ok",
+		Database:         database.Name,
+		Path:             database.Path,
+		OpenTransactions: d.openTransactions(database),
+	}
+	if info, err := os.Stat(resolved); err == nil {
+		preview.SourceBytes = info.Size()
+	}
+	if info, err := os.Stat(database.Path); err == nil {
+		preview.Bytes = info.Size()
+	}
+	return preview, nil
+}
+
+// RegisterBackupTools - Register the backup_database and restore_database tools
+func RegisterBackupTools(mcpServer *server.MCPServer, databases *Databases, cfg *config.Config) error {
+	zap.S().Debug("registering backup_database and restore_database tools")
+
+	if cfg.Confirmation.Enabled && cfg.Confirmation.TokenTTL <= 0 {
+		return fmt.Errorf("confirmation.token_ttl must be positive, got %s", cfg.Confirmation.TokenTTL)
+	}
+
+	// Define the backup tool
+	backupTool := mcp.NewTool("backup_database",
+		mcp.WithDescription("Copy the live database to a file inside the configured backup_dirs while the server keeps using it. "+
+			"Sends progress notifications when the call passes a progress token"),
+		mcp.WithString("path",
+			mcp.Description("Destination file inside one of the allowed backup directories"),
+			mcp.Required(),
+		),
+		mcp.WithString("method",
+			mcp.Description("online (default) copies the pages with the SQLite backup API and reports progress; vacuum writes a compacted copy with VACUUM INTO"),
+			mcp.Enum(BackupMethods...),
+		),
+		mcp.WithBoolean("overwrite",
+			mcp.Description("Replace an existing file at path"),
+		),
+		mcp.WithNumber("timeout_ms",
+			mcp.Description(timeoutDescription),
+			mcp.Min(1),
+		),
+		databaseArgument(databases),
+	)
+
+	mcpServer.AddTool(backupTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		path := request.GetString("path", "")
+		if path == "" {
+			return mcp.NewToolResultError("path parameter is required"), nil
+		}
+		method := strings.ToLower(request.GetString("method", BackupOnline))
+
+		database, err := requestDatabase(databases, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		timeout, err := callTimeout(cfg, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx, cancel := withCallTimeout(ctx, timeout)
+		defer cancel()
+
+		zap.S().Debugw("executing backup_database",
+			"database", database.Name,
+			"path", path,
+			"method", method)
+
+		result, err := database.Backup(ctx, path, method, request.GetBool("overwrite", false), progressNotifier(ctx, request))
+		if err != nil {
+			zap.S().Errorw("failed to back up database",
+				"database", database.Name,
+				"path", path,
+				"error", err)
+			return errorResult(ctx, timeout, err), nil
+		}
+		zap.S().Infow("backed up database",
+			"database", database.Name,
+			"path", result.Path,
+			"method", method,
+			"bytes", result.Bytes)
+
+		toolResult, err := jsonToolResult(result)
+		if err != nil {
+			zap.S().Errorw("failed to convert result to JSON", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return toolResult, nil
+	})
+
+	// restore_database writes and is left out when every database is read-only
+	if !databases.AnyWritable() {
+		return nil
+	}
+
+	// Define the restore tool
+	restoreTool := mcp.NewTool("restore_database",
+		mcp.WithDescription("Replace the contents of the database with a backup file from the configured backup_dirs. "+
+			"The file must pass PRAGMA integrity_check. Open transactions on the database are rolled back and session connections closed, so TEMP tables are lost. "+
+			"Needs confirmation unless confirmation is disabled"),
+		mcp.WithString("path",
+			mcp.Description("Backup file inside one of the allowed backup directories"),
+			mcp.Required(),
+		),
+		mcp.WithString("confirmation_token",
+			mcp.Description("Token returned when the restore was held back for confirmation. Repeat the same call with it to restore"),
+		),
+		mcp.WithNumber("timeout_ms",
+			mcp.Description(timeoutDescription+". A restore that times out leaves the database file unchanged, but the open transactions and session connections have already been dropped"),
+			mcp.Min(1),
+		),
+		databaseArgument(databases),
+	)
+
+	mcpServer.AddTool(restoreTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		path := request.GetString("path", "")
+		if path == "" {
+			return mcp.NewToolResultError("path parameter is required"), nil
+		}
+
+		database, err := callDatabase(databases, request.GetString("database", ""), "", true)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		// A confirmation token only runs the exact call it was issued for
+		token := request.GetString("confirmation_token", "")
+		key, err := confirmationKey("restore_database", database.Name, "", path, nil)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if token != "" {
+			if err := databases.confirmations.redeem(token, key, sessionIDFromContext(ctx)); err != nil {
+				zap.S().Warnw("rejected confirmation token", "error", err)
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			zap.S().Infow("restore_database confirmed", "database", database.Name)
+		}
+
+		timeout, err := callTimeout(cfg, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx, cancel := withCallTimeout(ctx, timeout)
+		defer cancel()
+
+		zap.S().Debugw("executing restore_database", "database", database.Name, "path", path)
+
+		// A restore replaces everything; it is checked and described first
+		if cfg.Confirmation.Enabled && token == "" {
+			preview, err := databases.previewRestore(ctx, database, path)
+			if err != nil {
+				zap.S().Warnw("rejected restore source", "path", path, "error", err)
+				return errorResult(ctx, timeout, err), nil
+			}
+			risks := []statementRisk{{
+				Statement: 1,
+				Line:      1,
+				Operation: "RESTORE",
+				Reason:    fmt.Sprintf("replaces every table of database %s with the contents of %s", database.Name, path),
+			}}
+			zap.S().Infow("restore_database held back for confirmation", "database", database.Name, "path", path)
+			return holdForConfirmation(ctx, databases, cfg.Confirmation, key, risks, preview), nil
+		}
+
+		result, err := databases.Restore(ctx, database, path, progressNotifier(ctx, request))
+		if err != nil {
+			zap.S().Errorw("failed to restore database",
+				"database", database.Name,
+				"path", path,
+				"error", err)
+			return errorResult(ctx, timeout, err), nil
+		}
+		zap.S().Infow("restored database",
+			"database", database.Name,
+			"path", result.Path,
+			"pages", result.Pages)
+
+		toolResult, err := jsonToolResult(result)
+		if err != nil {
+			zap.S().Errorw("failed to convert result to JSON", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return toolResult, nil
+	})
+
+	return nil
+}
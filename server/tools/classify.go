@@ -0,0 +1,260 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// statementClass - Classification of a single SQL statement
+type statementClass struct {
+	// Kind is the main verb of the statement, e.g. SELECT, INSERT, REPLACE, CREATE, PRAGMA.
+	// For WITH statements it is the verb of the statement that follows the CTEs.
+	Kind string
+	// Object is the object type for CREATE/DROP/ALTER, e.g. TABLE, INDEX, VIEW, TRIGGER, VIRTUAL TABLE
+	Object string
+	// Temp is set for CREATE TEMP/TEMPORARY statements
+	Temp bool
+	// ReadOnly is SQLite's own verdict from sqlite3_stmt_readonly
+	ReadOnly bool
+	// Params is the number of bind parameters in the statement
+	Params int
+	// NamedParams lists the distinct named placeholders (:name, @name, $name) in order of appearance
+	NamedParams []string
+	// Returning is set when the statement has a RETURNING clause and so returns rows
+	Returning bool
+}
+
+// queryKinds - Statement kinds that return rows and may be run by read_query
+var queryKinds = map[string]bool{
+	"SELECT": true,
+	"VALUES": true,
+	"PRAGMA": true,
+}
+
+// dmlKinds - Data-modifying statement kinds accepted by write_query
+var dmlKinds = map[string]bool{
+	"INSERT":  true,
+	"REPLACE": true,
+	"UPDATE":  true,
+	"DELETE":  true,
+}
+
+// transactionKinds - Transaction control statement kinds accepted by write_query
+var transactionKinds = map[string]bool{
+	"BEGIN":    true,
+	"COMMIT":   true,
+	"END":      true,
+	"ROLLBACK": true,
+}
+
+// IsQuery - Check whether the statement is a read-only statement that returns rows
+func (c statementClass) IsQuery() bool {
+	// EXPLAIN only describes the program and never runs it, even when SQLite
+	// reports the explained statement as a write
+	if c.Kind == "EXPLAIN" {
+		return true
+	}
+	return c.ReadOnly && queryKinds[c.Kind]
+}
+
+// IsDML - Check whether the statement modifies table data
+func (c statementClass) IsDML() bool {
+	return !c.ReadOnly && dmlKinds[c.Kind]
+}
+
+// IsTransactionControl - Check whether the statement begins or ends a transaction
+func (c statementClass) IsTransactionControl() bool {
+	return transactionKinds[c.Kind]
+}
+
+// IsInsert - Check whether the statement inserts rows and reports a last insert ID
+func (c statementClass) IsInsert() bool {
+	return c.Kind == "INSERT" || c.Kind == "REPLACE"
+}
+
+// classifyStatement - Prepare a single statement on the connection and ask SQLite what it is.
+// The statement is compiled but never stepped, so it has no effect on the database.
+func classifyStatement(ctx context.Context, conn *sql.Conn, stmt string) (statementClass, error) {
+	tokens := tokenizeSQL(stmt)
+	if len(tokens) == 0 {
+		return statementClass{}, fmt.Errorf("empty statement")
+	}
+	if len(splitTokens(stmt, tokens)) > 1 {
+		return statementClass{}, fmt.Errorf("multiple statements are not allowed here")
+	}
+
+	class := leadingKeywords(tokens)
+	class.NamedParams = namedPlaceholders(tokens)
+	class.Returning = hasReturningClause(tokens)
+
+	err := conn.Raw(func(driverConn any) error {
+		sqliteConn, ok := driverConn.(*sqlite3.SQLiteConn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection type %T", driverConn)
+		}
+
+		prepared, err := sqliteConn.Prepare(stmt)
+		if err != nil {
+			return err
+		}
+		defer prepared.Close()
+
+		sqliteStmt, ok := prepared.(*sqlite3.SQLiteStmt)
+		if !ok {
+			return fmt.Errorf("unexpected driver statement type %T", prepared)
+		}
+		class.ReadOnly = sqliteStmt.Readonly()
+		class.Params = sqliteStmt.NumInput()
+		return nil
+	})
+	if err != nil {
+		return statementClass{}, err
+	}
+
+	return class, nil
+}
+
+// authorizerAction - One action SQLite asked the authorizer about while compiling a statement
+type authorizerAction struct {
+	Action int
+	Arg1   string
+	Arg2   string
+	DBName string
+}
+
+// compiledActions - Compile a statement with an authorizer that records every action it performs,
+// including the ones of the triggers and foreign key actions it fires. The statement is never
+// stepped; the connection's own authorizer still decides and is restored afterwards.
+func compiledActions(conn *sql.Conn, stmt string, authorizer func(action int, arg1, arg2, dbName string) int) ([]authorizerAction, error) {
+	var actions []authorizerAction
+	err := conn.Raw(func(driverConn any) error {
+		sqliteConn, ok := driverConn.(*sqlite3.SQLiteConn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection type %T", driverConn)
+		}
+
+		sqliteConn.RegisterAuthorizer(func(action int, arg1, arg2, dbName string) int {
+			actions = append(actions, authorizerAction{Action: action, Arg1: arg1, Arg2: arg2, DBName: dbName})
+			if authorizer != nil {
+				return authorizer(action, arg1, arg2, dbName)
+			}
+			return sqlite3.SQLITE_OK
+		})
+		defer sqliteConn.RegisterAuthorizer(authorizer)
+
+		prepared, err := sqliteConn.Prepare(stmt)
+		if err != nil {
+			return err
+		}
+		return prepared.Close()
+	})
+	return actions, err
+}
+
+// leadingKeywords - Derive the statement kind and DDL object type from the leading tokens
+func leadingKeywords(tokens []sqlToken) statementClass {
+	var class statementClass
+	if tokens[0].Kind != tokenWord {
+		return class
+	}
+
+	class.Kind = tokens[0].upper()
+	rest := tokens[1:]
+
+	switch class.Kind {
+	case "WITH":
+		class.Kind = mainVerbAfterCTE(rest)
+	case "CREATE":
+		if len(rest) > 0 && (rest[0].isKeyword("TEMP") || rest[0].isKeyword("TEMPORARY")) {
+			class.Temp = true
+			rest = rest[1:]
+		}
+		if len(rest) > 0 && rest[0].isKeyword("UNIQUE") {
+			rest = rest[1:]
+		}
+		if len(rest) > 1 && rest[0].isKeyword("VIRTUAL") {
+			class.Object = "VIRTUAL " + rest[1].upper()
+		} else if len(rest) > 0 {
+			class.Object = rest[0].upper()
+		}
+	case "ROLLBACK":
+		// ROLLBACK [TRANSACTION] TO [SAVEPOINT] name only rewinds to a savepoint
+		for _, token := range rest {
+			if token.isKeyword("TO") {
+				class.Kind = "ROLLBACK TO"
+				break
+			}
+		}
+	case "DROP", "ALTER":
+		if len(rest) > 0 {
+			class.Object = rest[0].upper()
+		}
+	}
+
+	return class
+}
+
+// namedPlaceholders - Collect the distinct named placeholders of a statement
+func namedPlaceholders(tokens []sqlToken) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, token := range tokens {
+		if token.Kind != tokenParam || strings.HasPrefix(token.Text, "?") || seen[token.Text] {
+			continue
+		}
+		seen[token.Text] = true
+		names = append(names, token.Text)
+	}
+	return names
+}
+
+// hasReturningClause - Check whether a statement ends in a RETURNING clause outside of any parentheses
+func hasReturningClause(tokens []sqlToken) bool {
+	depth := 0
+	for _, token := range tokens {
+		switch {
+		case token.Text == "(":
+			depth++
+		case token.Text == ")":
+			depth--
+		case depth == 0 && token.isKeyword("RETURNING"):
+			return true
+		}
+	}
+	return false
+}
+
+// mainVerbAfterCTE - Find the statement verb that follows a WITH clause
+func mainVerbAfterCTE(tokens []sqlToken) string {
+	depth := 0
+	for _, token := range tokens {
+		switch {
+		case token.Text == "(":
+			depth++
+		case token.Text == ")":
+			depth--
+		case depth == 0 && token.Kind == tokenWord:
+			switch verb := token.upper(); verb {
+			case "SELECT", "VALUES", "INSERT", "REPLACE", "UPDATE", "DELETE":
+				return verb
+			}
+		}
+	}
+	return "WITH"
+}
+
+// statementKindName - Human readable statement kind for error messages
+func statementKindName(class statementClass) string {
+	name := class.Kind
+	if class.Temp {
+		name += " TEMP"
+	}
+	if class.Object != "" {
+		name += " " + class.Object
+	}
+	return strings.TrimSpace(name)
+}
@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLeadingKeywords(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want statementClass
+	}{
+		{"select 1", statementClass{Kind: "SELECT"}},
+		{"WITH c AS (SELECT 1) DELETE FROM t WHERE id IN c", statementClass{Kind: "DELETE"}},
+		{"WITH c(x) AS (VALUES (1)) SELECT x FROM c", statementClass{Kind: "SELECT"}},
+		{"WITH RECURSIVE c AS (SELECT 1 UNION ALL SELECT 1 FROM c) INSERT INTO t SELECT * FROM c", statementClass{Kind: "INSERT"}},
+		{"CREATE TABLE t (a)", statementClass{Kind: "CREATE", Object: "TABLE"}},
+		{"CREATE TEMP TABLE t (a)", statementClass{Kind: "CREATE", Object: "TABLE", Temp: true}},
+		{"CREATE UNIQUE INDEX i ON t (a)", statementClass{Kind: "CREATE", Object: "INDEX"}},
+		{"CREATE VIRTUAL TABLE f USING fts5(a)", statementClass{Kind: "CREATE", Object: "VIRTUAL TABLE"}},
+		{"DROP VIEW v", statementClass{Kind: "DROP", Object: "VIEW"}},
+		{"ALTER TABLE t ADD COLUMN b", statementClass{Kind: "ALTER", Object: "TABLE"}},
+		{"ROLLBACK", statementClass{Kind: "ROLLBACK"}},
+		{"ROLLBACK TRANSACTION TO SAVEPOINT s", statementClass{Kind: "ROLLBACK TO"}},
+		{"(SELECT 1)", statementClass{}},
+	}
+
+	for _, tt := range tests {
+		if got := leadingKeywords(tokenizeSQL(tt.sql)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("leadingKeywords(%q) = %+v, want %+v", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestNamedPlaceholders(t *testing.T) {
+	got := namedPlaceholders(tokenizeSQL("SELECT :a, ?, @b, :a, $c, ?2, ':d'"))
+	if want := []string{":a", "@b", "$c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("namedPlaceholders = %v, want %v", got, want)
+	}
+}
+
+func TestHasReturningClause(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"INSERT INTO t VALUES (1) RETURNING id", true},
+		{"DELETE FROM t WHERE id = 1 returning *", true},
+		{"INSERT INTO t VALUES (1) -- RETURNING id", false},
+		{`UPDATE t SET "returning" = 1`, false},
+		{"INSERT INTO t VALUES ('RETURNING')", false},
+	}
+
+	for _, tt := range tests {
+		if got := hasReturningClause(tokenizeSQL(tt.sql)); got != tt.want {
+			t.Errorf("hasReturningClause(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyStatement(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "CREATE TABLE t (id INTEGER PRIMARY KEY, a)"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		sql       string
+		query     bool
+		dml       bool
+		params    int
+		returning bool
+		wantErr   string
+	}{
+		{sql: "SELECT * FROM t WHERE id = ?", query: true, params: 1},
+		{sql: "  -- comment\n  select a from t", query: true},
+		{sql: "PRAGMA table_info(t)", query: true},
+		{sql: "EXPLAIN DELETE FROM t", query: true},
+		{sql: "WITH c AS (SELECT 1) SELECT * FROM c", query: true},
+		{sql: "INSERT INTO t (a) VALUES (:a) RETURNING id", dml: true, params: 1, returning: true},
+		{sql: "WITH c AS (SELECT 1) DELETE FROM t", dml: true},
+		{sql: "PRAGMA user_version = 3"},
+		{sql: "SELECT 1; SELECT 2", wantErr: "multiple statements"},
+		{sql: " -- nothing", wantErr: "empty statement"},
+		{sql: "SELECT * FROM missing", wantErr: "no such table"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			class, err := classifyStatement(ctx, conn, tt.sql)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if class.IsQuery() != tt.query || class.IsDML() != tt.dml || class.Params != tt.params || class.Returning != tt.returning {
+				t.Errorf("class = %+v: query %v, dml %v, want query %v, dml %v, params %d, returning %v",
+					class, class.IsQuery(), class.IsDML(), tt.query, tt.dml, tt.params, tt.returning)
+			}
+		})
+	}
+}
@@ -0,0 +1,378 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cnosuke/mcp-sqlite/config"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+)
+
+// statementRisk - Why a statement needs confirmation before it runs
+type statementRisk struct {
+	Statement int    `json:"statement"`
+	Line      int    `json:"line"`
+	Offset    int    `json:"offset"`
+	Operation string `json:"operation"`
+	Reason    string `json:"reason"`
+}
+
+// riskCollector - Collects the destructive statements of a script while it is dry-run
+type riskCollector struct {
+	rules      config.Confirmation
+	authorizer func(action int, arg1, arg2, dbName string) int
+	risks      []statementRisk
+}
+
+// add - Record a risk of the i-th statement
+func (r *riskCollector) add(i int, stmt sqlStatement, operation, reason string) {
+	r.risks = append(r.risks, statementRisk{
+		Statement: i + 1,
+		Line:      stmt.Line,
+		Offset:    stmt.Offset,
+		Operation: operation,
+		Reason:    reason,
+	})
+}
+
+// check - Record the risks that show before the statement runs: unfiltered DELETE and UPDATE,
+// DROP, and schema changes to protected tables
+func (r *riskCollector) check(conn *sql.Conn, i int, stmt sqlStatement, class statementClass) error {
+	if reason := staticRisk(stmt.Text, class); reason != "" {
+		r.add(i, stmt, statementKindName(class), reason)
+	}
+	if len(r.rules.ProtectedTables) == 0 || class.IsDML() || class.IsTransactionControl() {
+		return nil
+	}
+
+	actions, err := compiledActions(conn, stmt.Text, r.authorizer)
+	if err != nil {
+		return err
+	}
+	reported := make(map[tableRef]bool)
+	for _, table := range schemaChangeTargets(actions) {
+		if reported[table] || !isProtectedTable(table, r.rules.ProtectedTables) {
+			continue
+		}
+		reported[table] = true
+		r.add(i, stmt, statementKindName(class), fmt.Sprintf("changes the schema of protected table %s", table.qualifiedName()))
+	}
+	return nil
+}
+
+// checkDeleteCount - Count the rows a DELETE is about to remove, with the arguments it runs with, and record a risk
+// when they are more than the rules allow. Reports whether the risk was recorded; a DELETE that cannot be
+// counted beforehand is left to checkResult.
+func (r *riskCollector) checkDeleteCount(ctx context.Context, db queryer, i int, stmt sqlStatement, args []any) (bool, error) {
+	if r.rules.MaxDeleteRows < 0 {
+		return false, nil
+	}
+	query := deleteCountQuery(stmt.Text, tokenizeSQL(stmt.Text))
+	if query == "" {
+		return false, nil
+	}
+	var count int64
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return false, err
+	}
+	if count <= int64(r.rules.MaxDeleteRows) {
+		return false, nil
+	}
+	r.add(i, stmt, "DELETE", fmt.Sprintf("deletes %d rows, more than the %d allowed without confirmation", count, r.rules.MaxDeleteRows))
+	return true, nil
+}
+
+// checkResult - Record the risks that only show once the statement ran: a DELETE removing too many rows
+func (r *riskCollector) checkResult(i int, stmt sqlStatement, result statementResult) {
+	if r.rules.MaxDeleteRows >= 0 && result.Operation == "DELETE" && result.RowsAffected > int64(r.rules.MaxDeleteRows) {
+		r.add(i, stmt, result.Operation, fmt.Sprintf("deletes %d rows, more than the %d allowed without confirmation", result.RowsAffected, r.rules.MaxDeleteRows))
+	}
+}
+
+// staticRisk - The reason a statement is destructive by its text alone, or ""
+func staticRisk(stmt string, class statementClass) string {
+	switch class.Kind {
+	case "DELETE", "UPDATE":
+		if !hasWhereClause(tokenizeSQL(stmt)) {
+			return fmt.Sprintf("%s without WHERE affects every row of the table", class.Kind)
+		}
+	case "DROP":
+		return fmt.Sprintf("DROP %s cannot be undone", class.Object)
+	case "ALTER":
+		if dropsColumn(tokenizeSQL(stmt)) {
+			return "ALTER TABLE ... DROP COLUMN deletes the column's data"
+		}
+	}
+	return ""
+}
+
+// hasWhereClause - Check whether a DELETE or UPDATE has a WHERE clause of its own,
+// not only inside subqueries or CTEs
+func hasWhereClause(tokens []sqlToken) bool {
+	depth := 0
+	verb := false
+	for _, token := range tokens {
+		switch {
+		case token.Text == "(":
+			depth++
+		case token.Text == ")":
+			depth--
+		case depth == 0 && token.Kind == tokenWord:
+			switch token.upper() {
+			case "DELETE", "UPDATE":
+				verb = true
+			case "WHERE":
+				if verb {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// dropsColumn - Check whether a statement is ALTER TABLE [schema.]table DROP [COLUMN] column
+func dropsColumn(tokens []sqlToken) bool {
+	if len(tokens) < 4 || !tokens[0].isKeyword("ALTER") || !tokens[1].isKeyword("TABLE") {
+		return false
+	}
+	// tokens[2] is the table name, or the schema name when a dot follows
+	i := 3
+	if tokens[i].Text == "." {
+		i += 2
+	}
+	return i < len(tokens) && tokens[i].isKeyword("DROP")
+}
+
+// deleteCountQuery - Turn a DELETE into a SELECT count(*) over the rows it would delete, keeping its WITH clause,
+// table, alias, INDEXED BY and WHERE clause but not RETURNING. Returns "" when the statement cannot be
+// rewritten with the same placeholders, e.g. with ORDER BY or LIMIT, or with bind parameters after RETURNING.
+func deleteCountQuery(stmt string, tokens []sqlToken) string {
+	depth := 0
+	verb := -1
+	end := len(stmt)
+	for i, token := range tokens {
+		switch {
+		case token.Text == "(":
+			depth++
+		case token.Text == ")":
+			depth--
+		case depth != 0:
+		case verb < 0 && token.isKeyword("DELETE"):
+			if i+1 >= len(tokens) || !tokens[i+1].isKeyword("FROM") {
+				return ""
+			}
+			verb = i
+		case verb >= 0 && (token.isKeyword("ORDER") || token.isKeyword("LIMIT")):
+			return ""
+		case verb >= 0 && token.isKeyword("RETURNING"):
+			for _, rest := range tokens[i+1:] {
+				if rest.Kind == tokenParam {
+					return ""
+				}
+			}
+			end = token.Offset
+		}
+		if end != len(stmt) {
+			break
+		}
+	}
+	if verb < 0 {
+		return ""
+	}
+	deleteToken := tokens[verb]
+	return stmt[:deleteToken.Offset] + "SELECT count(*)" + stmt[deleteToken.Offset+len(deleteToken.Text):end]
+}
+
+// countsDeleteOnly - Check whether a script is a single DELETE that only needs confirmation when it removes
+// too many rows, which its row count tells before it runs
+func countsDeleteOnly(stmts []sqlStatement, rules config.Confirmation) bool {
+	if len(stmts) != 1 || rules.MaxDeleteRows < 0 {
+		return false
+	}
+	tokens := tokenizeSQL(stmts[0].Text)
+	class := leadingKeywords(tokens)
+	return class.Kind == "DELETE" && staticRisk(stmts[0].Text, class) == "" && deleteCountQuery(stmts[0].Text, tokens) != ""
+}
+
+// mayNeedConfirmation - Check from the statement texts whether a script has to be dry-run
+// to find out if it needs confirmation
+func mayNeedConfirmation(stmts []sqlStatement, rules config.Confirmation) bool {
+	for _, stmt := range stmts {
+		tokens := tokenizeSQL(stmt.Text)
+		if len(tokens) == 0 {
+			continue
+		}
+		class := leadingKeywords(tokens)
+		switch {
+		case staticRisk(stmt.Text, class) != "":
+			return true
+		case class.Kind == "DELETE" && rules.MaxDeleteRows >= 0:
+			return true
+		case (class.Kind == "ALTER" || class.Kind == "DROP" || class.Kind == "CREATE") && len(rules.ProtectedTables) > 0:
+			return true
+		}
+	}
+	return false
+}
+
+// schemaChangeTargets - The tables whose schema the compiled actions change
+func schemaChangeTargets(actions []authorizerAction) []tableRef {
+	var tables []tableRef
+	for _, action := range actions {
+		var table tableRef
+		switch action.Action {
+		case sqlite3.SQLITE_DROP_TABLE, sqlite3.SQLITE_DROP_TEMP_TABLE, sqlite3.SQLITE_DROP_VTABLE:
+			table = tableRef{Schema: action.DBName, Name: action.Arg1}
+		case sqlite3.SQLITE_ALTER_TABLE:
+			table = tableRef{Schema: action.Arg1, Name: action.Arg2}
+		case sqlite3.SQLITE_CREATE_INDEX, sqlite3.SQLITE_CREATE_TEMP_INDEX,
+			sqlite3.SQLITE_DROP_INDEX, sqlite3.SQLITE_DROP_TEMP_INDEX,
+			sqlite3.SQLITE_CREATE_TRIGGER, sqlite3.SQLITE_CREATE_TEMP_TRIGGER,
+			sqlite3.SQLITE_DROP_TRIGGER, sqlite3.SQLITE_DROP_TEMP_TRIGGER:
+			table = tableRef{Schema: action.DBName, Name: action.Arg2}
+		default:
+			continue
+		}
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+// isProtectedTable - Check a table against the protected_tables setting. Entries are name or
+// schema.name; an unqualified entry protects the table in every schema.
+func isProtectedTable(table tableRef, protected []string) bool {
+	for _, entry := range protected {
+		schema, name, ok := parseTableName(entry)
+		if !ok {
+			schema, name = "", entry
+		}
+		if !strings.EqualFold(name, table.Name) {
+			continue
+		}
+		if schema == "" || strings.EqualFold(schema, table.Schema) {
+			return true
+		}
+	}
+	return false
+}
+
+// pendingConfirmation - A script held back until the call is repeated with its token
+type pendingConfirmation struct {
+	key       string
+	sessionID string
+	expires   time.Time
+}
+
+// confirmationRegistry - Outstanding confirmation tokens
+type confirmationRegistry struct {
+	mu      sync.Mutex
+	byToken map[string]pendingConfirmation
+}
+
+// confirmationKey - Fingerprint of what a token confirms: the same tool called with the same query
+// and params against the same database and transaction
+func confirmationKey(tool, database, transaction, query string, params any) (string, error) {
+	encoded, err := json.Marshal(struct {
+		Tool        string `json:"tool"`
+		Database    string `json:"database"`
+		Transaction string `json:"transaction"`
+		Query       string `json:"query"`
+		Params      any    `json:"params"`
+	}{tool, database, transaction, query, params})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// issue - Hand out a token that confirms the fingerprinted call for the TTL
+func (r *confirmationRegistry) issue(key, sessionID string, ttl time.Duration) (string, time.Time, error) {
+	token, err := newHandle("cf_")
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now := time.Now()
+	expires := now.Add(ttl)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.byToken == nil {
+		r.byToken = make(map[string]pendingConfirmation)
+	}
+	for t, pending := range r.byToken {
+		if now.After(pending.expires) {
+			delete(r.byToken, t)
+		}
+	}
+	r.byToken[token] = pendingConfirmation{key: key, sessionID: sessionID, expires: expires}
+	return token, expires, nil
+}
+
+// redeem - Use up a token for the fingerprinted call. A token presented with a different
+// call stays valid, so the caller can still repeat the original one.
+func (r *confirmationRegistry) redeem(token, key, sessionID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pending, ok := r.byToken[token]
+	if !ok {
+		return fmt.Errorf("unknown confirmation token %s; it was already used or never issued", token)
+	}
+	if time.Now().After(pending.expires) {
+		delete(r.byToken, token)
+		return fmt.Errorf("confirmation token %s expired; repeat the call without it to get a new one", token)
+	}
+	if pending.sessionID != sessionID {
+		return fmt.Errorf("confirmation token %s belongs to a different session", token)
+	}
+	if pending.key != key {
+		return fmt.Errorf("confirmation token %s was issued for a different query, params, database or transaction", token)
+	}
+	delete(r.byToken, token)
+	return nil
+}
+
+// confirmationResult - Response body of a call held back for confirmation
+type confirmationResult struct {
+	ConfirmationRequired bool            `json:"confirmation_required"`
+	ConfirmationToken    string          `json:"confirmation_token"`
+	ExpiresAt            string          `json:"expires_at"`
+	Message              string          `json:"message"`
+	Risks                []statementRisk `json:"risks"`
+	// Preview shows what the call would do, in a shape that depends on the tool
+	Preview any `json:"preview"`
+}
+
+// holdForConfirmation - Issue a token for the fingerprinted call and describe why it was held back
+func holdForConfirmation(ctx context.Context, databases *Databases, rules config.Confirmation, key string, risks []statementRisk, preview any) *mcp.CallToolResult {
+	token, expires, err := databases.confirmations.issue(key, sessionIDFromContext(ctx), rules.TokenTTL)
+	if err != nil {
+		zap.S().Errorw("failed to issue confirmation token", "error", err)
+		return mcp.NewToolResultError(err.Error())
+	}
+
+	result, err := jsonToolResult(confirmationResult{
+		ConfirmationRequired: true,
+		ConfirmationToken:    token,
+		ExpiresAt:            expires.UTC().Format(time.RFC3339),
+		Message:              "Nothing was changed. Repeat the same call with confirmation_token to execute it",
+		Risks:                risks,
+		Preview:              preview,
+	})
+	if err != nil {
+		zap.S().Errorw("failed to convert result to JSON", "error", err)
+		return mcp.NewToolResultError(err.Error())
+	}
+	return result
+}
@@ -0,0 +1,53 @@
+package tools
+
+import "testing"
+
+func TestDeleteCountQuery(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"DELETE FROM t WHERE id > ?", "SELECT count(*) FROM t WHERE id > ?"},
+		{"delete from main.t as x indexed by i where x.a = :a", "SELECT count(*) from main.t as x indexed by i where x.a = :a"},
+		{"DELETE FROM t", "SELECT count(*) FROM t"},
+		{
+			"WITH old AS (SELECT id FROM t WHERE a < 0) DELETE FROM t WHERE id IN old",
+			"WITH old AS (SELECT id FROM t WHERE a < 0) SELECT count(*) FROM t WHERE id IN old",
+		},
+		{"DELETE FROM t WHERE id IN (SELECT id FROM u ORDER BY id LIMIT 3) RETURNING id", "SELECT count(*) FROM t WHERE id IN (SELECT id FROM u ORDER BY id LIMIT 3) "},
+		{"DELETE FROM t WHERE a = 'RETURNING' -- RETURNING", "SELECT count(*) FROM t WHERE a = 'RETURNING' -- RETURNING"},
+		// Placeholders after RETURNING would be bound to the wrong query
+		{"DELETE FROM t WHERE a = ? RETURNING id + ?", ""},
+		// ORDER BY and LIMIT of the DELETE itself limit the rows it removes
+		{"DELETE FROM t WHERE a > 0 ORDER BY a LIMIT 10", ""},
+		{"UPDATE t SET a = 1", ""},
+	}
+
+	for _, tt := range tests {
+		if got := deleteCountQuery(tt.sql, tokenizeSQL(tt.sql)); got != tt.want {
+			t.Errorf("deleteCountQuery(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestDropsColumn(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"ALTER TABLE t DROP COLUMN a", true},
+		{"alter table t drop a", true},
+		{`ALTER TABLE main."t" DROP COLUMN "a"`, true},
+		{"ALTER TABLE t ADD COLUMN drop_date TEXT", false},
+		{`ALTER TABLE t ADD COLUMN b TEXT DEFAULT 'DROP'`, false},
+		{`ALTER TABLE t RENAME COLUMN a TO "drop"`, false},
+		{`ALTER TABLE "drop" RENAME TO t`, false},
+		{"DROP TABLE t", false},
+	}
+
+	for _, tt := range tests {
+		if got := dropsColumn(tokenizeSQL(tt.sql)); got != tt.want {
+			t.Errorf("dropsColumn(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
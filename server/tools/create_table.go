@@ -2,10 +2,9 @@ package tools
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
-	"strings"
 
+	"github.com/cnosuke/mcp-sqlite/config"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"go.uber.org/zap"
@@ -13,57 +12,90 @@ import (
 
 // CreateTableArgs - Arguments for create_table tool (kept for testing compatibility)
 type CreateTableArgs struct {
-	Query string `json:"query" jsonschema:"description=CREATE TABLE SQL statement"`
+	Query     string `json:"query" jsonschema:"description=CREATE TABLE SQL statement"`
+	TimeoutMS int    `json:"timeout_ms,omitempty" jsonschema:"description=Timeout in milliseconds"`
 }
 
 // RegisterCreateTableTool - Register the create_table tool
-func RegisterCreateTableTool(mcpServer *server.MCPServer, db *sql.DB) error {
+func RegisterCreateTableTool(mcpServer *server.MCPServer, databases *Databases, cfg *config.Config) error {
 	zap.S().Debug("registering create_table tool")
 
 	// Define the tool
 	tool := mcp.NewTool("create_table",
-		mcp.WithDescription("Create new tables in the database"),
+		mcp.WithDescription("Create new tables in the database. schema_change also handles ALTER, DROP, indexes, views and triggers"),
 		mcp.WithString("query",
-			mcp.Description("CREATE TABLE SQL statement"),
+			mcp.Description("CREATE TABLE SQL statement. CREATE TEMP TABLE creates a table that lasts for the client session"),
 			mcp.Required(),
 		),
+		mcp.WithNumber("timeout_ms",
+			mcp.Description(timeoutDescription),
+			mcp.Min(1),
+		),
+		databaseArgument(databases),
 	)
 
 	// Add the tool handler
 	mcpServer.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		// Extract query parameter
-		query, ok := request.Params.Arguments["query"].(string)
+		query, ok := request.GetArguments()["query"].(string)
 		if !ok || query == "" {
 			return mcp.NewToolResultError("query parameter is required"), nil
 		}
 
+		database, err := writableDatabase(databases, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		timeout, err := callTimeout(cfg, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx, cancel := withCallTimeout(ctx, timeout)
+		defer cancel()
+
 		zap.S().Debugw("executing create_table", "query", query)
 
-		// Verify query starts with CREATE TABLE
-		if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(query)), "CREATE TABLE") {
-			zap.S().Warnw("invalid query type for create_table", "query", query)
+		// Use one connection for classification and execution
+		conn, release, err := database.Conn(ctx)
+		if err != nil {
+			zap.S().Errorw("failed to get database connection", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		defer release()
+
+		// Verify the statement is a CREATE TABLE statement
+		class, err := classifyStatement(ctx, conn, query)
+		if err != nil {
+			zap.S().Warnw("failed to classify query",
+				"query", query,
+				"error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if class.Kind != "CREATE" || class.Object != "TABLE" {
+			zap.S().Warnw("invalid query type for create_table",
+				"query", query,
+				"kind", statementKindName(class))
 			return mcp.NewToolResultError("create_table only supports CREATE TABLE statements"), nil
 		}
 
 		// Execute query
 		zap.S().Debugw("creating table", "query", query)
-		_, err := db.ExecContext(ctx, query)
+		_, err = conn.ExecContext(ctx, query)
 		if err != nil {
 			zap.S().Errorw("failed to create table",
 				"query", query,
 				"error", err)
-			return mcp.NewToolResultError(err.Error()), nil
+			return errorResult(ctx, timeout, err), nil
 		}
 
-		// Extract table name (simple implementation)
-		parts := strings.Split(query, "CREATE TABLE")
-		if len(parts) < 2 {
-			zap.S().Errorw("could not extract table name", "query", query)
+		// The statement compiled and ran, so its name parses
+		target, err := parseDDLTarget(query)
+		if err != nil {
+			zap.S().Errorw("could not extract table name", "query", query, "error", err)
 			return mcp.NewToolResultError("could not extract table name"), nil
 		}
-		tablePart := strings.TrimSpace(parts[1])
-		tableName := strings.Split(tablePart, " ")[0]
-		tableName = strings.Trim(tableName, "`[]\"' ")
+		tableName := target.Name
 		zap.S().Infow("table created successfully", "table_name", tableName)
 
 		return mcp.NewToolResultText(fmt.Sprintf("Table '%s' was successfully created", tableName)), nil
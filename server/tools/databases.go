@@ -0,0 +1,186 @@
+package tools
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Database - A named database the tools can operate on
+type Database struct {
+	Name     string
+	Path     string
+	ReadOnly bool
+	DB       *sql.DB
+	// AttachDirs are the directories attach_database may open files from; empty disables the tool
+	AttachDirs []string
+	// BackupDirs are the directories backups may be written to and restored from; empty disables them
+	BackupDirs []string
+	// ExportDirs are the directories export_query may write files to; empty disables the tool for the database
+	ExportDirs []string
+	// ImportDirs are the directories import_data may read files from; empty allows inline content only
+	ImportDirs []string
+	// MigrationsDir holds the migrations of the database; empty disables the migration tools for it
+	MigrationsDir string
+	// Authorizer is the authorizer installed on every connection, lifted while attachments are applied
+	Authorizer func(action int, arg1, arg2, dbName string) int
+
+	// MaxIdleConns is the idle connection limit set on DB, restored after a restore closes the idle connections;
+	// 0 is database/sql's default and a negative value keeps none
+	MaxIdleConns int
+	// IdleTimeout closes a connection pinned to a client session after this long without a call; 0 disables pinning
+	IdleTimeout time.Duration
+
+	mu          sync.Mutex
+	attachments map[string]attachment
+
+	sessionsMu sync.Mutex
+	sessions   map[string]*sessionConn
+	// generation is raised by a restore; pooled connections taken out before it are closed when released
+	generation atomic.Uint64
+}
+
+// inMemory - Check whether the database lives in memory without a shared cache, where every connection
+// opens a database of its own
+func (d *Database) inMemory() bool {
+	if strings.Contains(d.Path, "cache=shared") {
+		return false
+	}
+	return d.Path == "" || d.Path == ":memory:" || strings.HasPrefix(d.Path, "file::memory:") || strings.Contains(d.Path, "mode=memory")
+}
+
+// Databases - The configured databases, selected by the database tool argument
+type Databases struct {
+	byName      map[string]*Database
+	names       []string
+	defaultName string
+
+	transactions  transactionRegistry
+	confirmations confirmationRegistry
+}
+
+// NewDatabases - Create the set of databases with the one used when a call does not name a database
+func NewDatabases(defaultName string, databases ...*Database) (*Databases, error) {
+	d := &Databases{byName: make(map[string]*Database, len(databases)), defaultName: defaultName}
+	for _, database := range databases {
+		if _, ok := d.byName[database.Name]; ok {
+			return nil, fmt.Errorf("duplicate database name: %s", database.Name)
+		}
+		d.byName[database.Name] = database
+		d.names = append(d.names, database.Name)
+	}
+	sort.Strings(d.names)
+
+	if _, ok := d.byName[defaultName]; !ok {
+		return nil, fmt.Errorf("default database %q is not configured", defaultName)
+	}
+	return d, nil
+}
+
+// Get - Look up a database by name; an empty name selects the default database
+func (d *Databases) Get(name string) (*Database, error) {
+	if name == "" {
+		name = d.defaultName
+	}
+	database, ok := d.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown database: %s (configured: %v)", name, d.names)
+	}
+	return database, nil
+}
+
+// Default - The database used when a call does not name one
+func (d *Databases) Default() *Database {
+	return d.byName[d.defaultName]
+}
+
+// DefaultName - Name of the default database
+func (d *Databases) DefaultName() string {
+	return d.defaultName
+}
+
+// Names - Names of all databases in sorted order
+func (d *Databases) Names() []string {
+	return d.names
+}
+
+// AnyWritable - Check whether at least one database accepts writes
+func (d *Databases) AnyWritable() bool {
+	for _, database := range d.byName {
+		if !database.ReadOnly {
+			return true
+		}
+	}
+	return false
+}
+
+// AnyAttachable - Check whether attach_database may open files for at least one database
+func (d *Databases) AnyAttachable() bool {
+	for _, database := range d.byName {
+		if len(database.AttachDirs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// AnyBackups - Check whether backups are enabled for at least one database
+func (d *Databases) AnyBackups() bool {
+	for _, database := range d.byName {
+		if len(database.BackupDirs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// AnyExports - Check whether export_query may write files for at least one database
+func (d *Databases) AnyExports() bool {
+	for _, database := range d.byName {
+		if len(database.ExportDirs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// AnyMigrations - Check whether at least one database has a migrations directory
+func (d *Databases) AnyMigrations() bool {
+	for _, database := range d.byName {
+		if database.MigrationsDir != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// databaseArgument - The optional database argument shared by all tools
+func databaseArgument(databases *Databases) mcp.ToolOption {
+	return mcp.WithString("database",
+		mcp.Description(fmt.Sprintf("Name of the configured database to use (default %s); list_databases shows all of them", databases.DefaultName())),
+		mcp.Enum(databases.Names()...),
+	)
+}
+
+// requestDatabase - Resolve the database argument of a tool call
+func requestDatabase(databases *Databases, request mcp.CallToolRequest) (*Database, error) {
+	return databases.Get(request.GetString("database", ""))
+}
+
+// writableDatabase - Resolve the database argument of a mutating tool call, rejecting read-only databases
+func writableDatabase(databases *Databases, request mcp.CallToolRequest) (*Database, error) {
+	database, err := requestDatabase(databases, request)
+	if err != nil {
+		return nil, err
+	}
+	if database.ReadOnly {
+		return nil, fmt.Errorf("database %s is read-only", database.Name)
+	}
+	return database, nil
+}
@@ -2,10 +2,9 @@ package tools
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
-	"fmt"
 
+	"github.com/cnosuke/mcp-sqlite/config"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"go.uber.org/zap"
@@ -13,81 +12,85 @@ import (
 
 // DescribeTableArgs - Arguments for describe_table tool (kept for testing compatibility)
 type DescribeTableArgs struct {
-	TableName string `json:"table_name" jsonschema:"description=Name of table to describe"`
+	TableName string `json:"table_name" jsonschema:"description=Name of table or view to describe, optionally qualified as schema.table"`
+	TimeoutMS int    `json:"timeout_ms,omitempty" jsonschema:"description=Timeout in milliseconds"`
 }
 
 // RegisterDescribeTableTool - Register the describe_table tool
-func RegisterDescribeTableTool(mcpServer *server.MCPServer, db *sql.DB) error {
+func RegisterDescribeTableTool(mcpServer *server.MCPServer, databases *Databases, cfg *config.Config) error {
 	zap.S().Debug("registering describe_table tool")
 
 	// Define the tool
 	tool := mcp.NewTool("describe_table",
-		mcp.WithDescription("View schema information for a specific table"),
+		mcp.WithDescription("View schema information for a specific table: columns (including hidden and generated columns), "+
+			"indexes, foreign keys, triggers, WITHOUT ROWID and STRICT flags and the original CREATE statement"),
 		mcp.WithString("table_name",
-			mcp.Description("Name of table to describe"),
+			mcp.Description("Name of table or view to describe. Use schema.table for tables in temp or attached databases; quote parts containing dots, e.g. \"my.db\".\"my table\""),
 			mcp.Required(),
 		),
+		mcp.WithNumber("timeout_ms",
+			mcp.Description(timeoutDescription),
+			mcp.Min(1),
+		),
+		databaseArgument(databases),
 	)
 
 	// Add the tool handler
 	mcpServer.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		// Extract table_name parameter
-		tableName, ok := request.Params.Arguments["table_name"].(string)
+		tableName, ok := request.GetArguments()["table_name"].(string)
 		if !ok || tableName == "" {
 			return mcp.NewToolResultError("table_name parameter is required"), nil
 		}
 
-		zap.S().Debugw("executing describe_table", "table_name", tableName)
+		database, err := requestDatabase(databases, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
 
-		// Get table schema information
-		query := fmt.Sprintf("PRAGMA table_info(%s)", tableName)
-		zap.S().Debugw("querying table schema", "query", query)
-		rows, err := db.QueryContext(ctx, query)
+		timeout, err := callTimeout(cfg, request)
 		if err != nil {
-			zap.S().Errorw("failed to get table information",
-				"table_name", tableName,
-				"error", err)
 			return mcp.NewToolResultError(err.Error()), nil
 		}
-		defer rows.Close()
+		ctx, cancel := withCallTimeout(ctx, timeout)
+		defer cancel()
 
-		// Slice to store column information
-		var columns []map[string]interface{}
+		zap.S().Debugw("executing describe_table", "table_name", tableName)
 
-		// Process each row
-		columnCount := 0
-		for rows.Next() {
-			var cid int
-			var name, dataType string
-			var notNull, pk int
-			var dfltValue interface{}
+		// Use one connection so that temp and attached databases are seen consistently
+		conn, release, err := database.InspectConn(ctx)
+		if err != nil {
+			zap.S().Errorw("failed to get database connection", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		defer release()
 
-			if err := rows.Scan(&cid, &name, &dataType, &notNull, &dfltValue, &pk); err != nil {
-				zap.S().Errorw("failed to scan column information", "error", err)
-				return mcp.NewToolResultError(err.Error()), nil
-			}
+		// Check the table exists before building any SQL from its name
+		table, err := resolveTable(ctx, conn, tableName)
+		if err != nil {
+			zap.S().Warnw("failed to resolve table",
+				"table_name", tableName,
+				"error", err)
+			return errorResult(ctx, timeout, err), nil
+		}
 
-			column := map[string]interface{}{
-				"name":        name,
-				"type":        dataType,
-				"not_null":    notNull == 1,
-				"default":     dfltValue,
-				"primary_key": pk == 1,
-			}
-			columns = append(columns, column)
-			columnCount++
-			zap.S().Debugw("column found",
-				"name", name,
-				"type", dataType,
-				"not_null", notNull == 1,
-				"primary_key", pk == 1)
+		// Get table schema information
+		desc, err := describeTable(ctx, conn, table)
+		if err != nil {
+			zap.S().Errorw("failed to get table information",
+				"table_name", tableName,
+				"error", err)
+			return errorResult(ctx, timeout, err), nil
 		}
 		zap.S().Debugw("table schema retrieved",
-			"table_name", tableName,
-			"column_count", columnCount)
+			"table_name", table.qualifiedName(),
+			"column_count", len(desc.Columns),
+			"index_count", len(desc.Indexes),
+			"foreign_key_count", len(desc.ForeignKeys),
+			"trigger_count", len(desc.Triggers))
 
 		// Convert result to JSON
-		jsonResult, err := json.Marshal(columns)
+		jsonResult, err := json.Marshal(desc)
 		if err != nil {
 			zap.S().Errorw("failed to convert result to JSON", "error", err)
 			return mcp.NewToolResultError(err.Error()), nil
@@ -0,0 +1,301 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+)
+
+// dryRunSavepoint - Savepoint a dry run executes in; rolling back to it undoes the script
+const dryRunSavepoint = `"mcp_sqlite_dry_run"`
+
+// dryRunSampleRows - Number of changed rows sampled per table by a dry run
+const dryRunSampleRows = 5
+
+// captureTablePrefix - Prefix of the TEMP tables and triggers that record the changes of a dry run
+const captureTablePrefix = "mcp_sqlite_dry_run_"
+
+// captureCountsTable - TEMP table counting the changes of a dry run per captured table
+const captureCountsTable = captureTablePrefix + "counts"
+
+// changeCapture - Records the rows a dry run changes with TEMP triggers on the tables its
+// statements write to. The triggers and tables live inside the dry run's savepoint and
+// disappear with its rollback.
+type changeCapture struct {
+	// authorizer is the database's authorizer, restored after looking for target tables
+	authorizer func(action int, arg1, arg2, dbName string) int
+	targets    []*captureTarget
+}
+
+// captureTarget - A table whose changes are recorded
+type captureTarget struct {
+	table   tableRef
+	columns []string
+	// captured is false for views and virtual tables, which cannot have AFTER triggers
+	captured bool
+}
+
+// tableChanges - Changes a dry run made to one table
+type tableChanges struct {
+	Table    string      `json:"table"`
+	Columns  []string    `json:"columns,omitempty"`
+	Inserted int64       `json:"inserted"`
+	Updated  int64       `json:"updated"`
+	Deleted  int64       `json:"deleted"`
+	Samples  []rowChange `json:"samples"`
+	// Note explains why a table has no samples
+	Note string `json:"note,omitempty"`
+}
+
+// rowChange - One sampled row change, with the row before and after it in column order
+type rowChange struct {
+	Operation string        `json:"operation"`
+	Before    []interface{} `json:"before,omitempty"`
+	After     []interface{} `json:"after,omitempty"`
+}
+
+// watch - Start recording changes to the tables the statement writes to, including
+// the ones its triggers and foreign key actions write to
+func (c *changeCapture) watch(ctx context.Context, conn *sql.Conn, stmt string) error {
+	tables, err := c.writeTargets(conn, stmt)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if c.find(table) != nil {
+			continue
+		}
+		target, err := c.install(ctx, conn, table, len(c.targets)+1)
+		if err != nil {
+			return fmt.Errorf("failed to capture changes to %s: %w", table.qualifiedName(), err)
+		}
+		c.targets = append(c.targets, target)
+	}
+	return nil
+}
+
+// writeTargets - The tables the statement writes to, directly or through triggers and foreign key actions
+func (c *changeCapture) writeTargets(conn *sql.Conn, stmt string) ([]tableRef, error) {
+	actions, err := compiledActions(conn, stmt, c.authorizer)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []tableRef
+	for _, action := range actions {
+		switch action.Action {
+		case sqlite3.SQLITE_INSERT, sqlite3.SQLITE_UPDATE, sqlite3.SQLITE_DELETE:
+		default:
+			continue
+		}
+		if strings.HasPrefix(action.Arg1, captureTablePrefix) || strings.HasPrefix(action.Arg1, "sqlite_") {
+			continue
+		}
+		table := tableRef{Schema: action.DBName, Name: action.Arg1}
+		seen := false
+		for _, t := range tables {
+			if t == table {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			tables = append(tables, table)
+		}
+	}
+	return tables, nil
+}
+
+// find - The target recording changes to the table, if any
+func (c *changeCapture) find(table tableRef) *captureTarget {
+	for _, t := range c.targets {
+		if t.table.Schema == table.Schema && t.table.Name == table.Name {
+			return t
+		}
+	}
+	return nil
+}
+
+// install - Create the TEMP table and triggers recording the changes to one table
+func (c *changeCapture) install(ctx context.Context, conn *sql.Conn, table tableRef, index int) (*captureTarget, error) {
+	target := &captureTarget{table: table}
+
+	var objectType, definition string
+	query := fmt.Sprintf("SELECT type, IFNULL(sql, '') FROM %s.sqlite_master WHERE name = ?", quoteIdentifier(table.Schema))
+	if err := conn.QueryRowContext(ctx, query, table.Name).Scan(&objectType, &definition); err != nil {
+		return nil, err
+	}
+	if objectType != "table" || strings.HasPrefix(strings.ToUpper(definition), "CREATE VIRTUAL") {
+		return target, nil
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT * FROM "+table.quoted()+" WHERE 0")
+	if err != nil {
+		return nil, err
+	}
+	target.columns, err = rows.Columns()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	captureTable := quoteIdentifier(fmt.Sprintf("%s%d", captureTablePrefix, index))
+	counts := quoteIdentifier(captureCountsTable)
+	statements := []string{
+		fmt.Sprintf("CREATE TEMP TABLE IF NOT EXISTS %s (target INTEGER PRIMARY KEY, inserted INTEGER NOT NULL DEFAULT 0, updated INTEGER NOT NULL DEFAULT 0, deleted INTEGER NOT NULL DEFAULT 0)", counts),
+		fmt.Sprintf("INSERT INTO temp.%s (target) VALUES (%d)", counts, index),
+		fmt.Sprintf("CREATE TEMP TABLE %s AS SELECT 0 AS change, '' AS operation, '' AS side, * FROM %s WHERE 0", captureTable, table.quoted()),
+	}
+
+	// Trigger bodies cannot qualify table names; the TEMP tables are found first
+	sample := func(operation, side, row string) string {
+		values := make([]string, len(target.columns))
+		for i, column := range target.columns {
+			values[i] = row + "." + quoteIdentifier(column)
+		}
+		return fmt.Sprintf("INSERT INTO %s SELECT inserted + updated + deleted, '%s', '%s', %s FROM %s WHERE target = %d AND inserted + updated + deleted <= %d;",
+			captureTable, operation, side, strings.Join(values, ", "), counts, index, dryRunSampleRows)
+	}
+	for _, event := range []struct {
+		operation, counter string
+		samples            []string
+	}{
+		{"insert", "inserted", []string{sample("insert", "after", "NEW")}},
+		{"update", "updated", []string{sample("update", "before", "OLD"), sample("update", "after", "NEW")}},
+		{"delete", "deleted", []string{sample("delete", "before", "OLD")}},
+	} {
+		trigger := quoteIdentifier(fmt.Sprintf("%s%d_%s", captureTablePrefix, index, event.operation))
+		statements = append(statements, fmt.Sprintf("CREATE TEMP TRIGGER %s AFTER %s ON %s BEGIN UPDATE %s SET %s = %s + 1 WHERE target = %d; %s END",
+			trigger, strings.ToUpper(event.operation), table.quoted(), counts, event.counter, event.counter, index, strings.Join(event.samples, " ")))
+	}
+
+	for _, statement := range statements {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return nil, err
+		}
+	}
+	target.captured = true
+	return target, nil
+}
+
+// changes - Read the counts and samples recorded for every target, in the order the tables were first written to
+func (c *changeCapture) changes(ctx context.Context, conn *sql.Conn, encoder *valueEncoder) ([]tableChanges, error) {
+	result := make([]tableChanges, 0, len(c.targets))
+	for i, target := range c.targets {
+		changes := tableChanges{Table: target.table.qualifiedName(), Samples: []rowChange{}}
+		if !target.captured {
+			changes.Note = "changes to views and virtual tables are not sampled"
+			result = append(result, changes)
+			continue
+		}
+		changes.Columns = target.columns
+		index := i + 1
+
+		query := fmt.Sprintf("SELECT inserted, updated, deleted FROM temp.%s WHERE target = ?", quoteIdentifier(captureCountsTable))
+		if err := conn.QueryRowContext(ctx, query, index).Scan(&changes.Inserted, &changes.Updated, &changes.Deleted); err != nil {
+			return nil, err
+		}
+
+		rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT * FROM temp.%s ORDER BY rowid", quoteIdentifier(fmt.Sprintf("%s%d", captureTablePrefix, index))))
+		if err != nil {
+			return nil, err
+		}
+		lastChange := int64(-1)
+		for rows.Next() {
+			var change int64
+			var operation, side string
+			values := make([]interface{}, len(target.columns))
+			dest := []interface{}{&change, &operation, &side}
+			for i := range values {
+				dest = append(dest, &values[i])
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			for i, val := range values {
+				values[i] = encoder.encode(val)
+			}
+
+			// The before and after rows of an update share a change number
+			if change != lastChange {
+				changes.Samples = append(changes.Samples, rowChange{Operation: operation})
+				lastChange = change
+			}
+			sample := &changes.Samples[len(changes.Samples)-1]
+			if side == "before" {
+				sample.Before = values
+			} else {
+				sample.After = values
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		result = append(result, changes)
+	}
+	return result, nil
+}
+
+// dryRunResult - Response body of write_query with dry_run set
+type dryRunResult struct {
+	DryRun     bool               `json:"dry_run"`
+	Summary    string             `json:"summary"`
+	Statements []statementOutcome `json:"statements"`
+	Tables     []tableChanges     `json:"tables"`
+}
+
+// newDryRunResult - Describe the statements of a dry run and the changes they made
+func newDryRunResult(results []statementResult, tables []tableChanges) dryRunResult {
+	return dryRunResult{
+		DryRun:     true,
+		Summary:    formatResponse(results) + ". All changes were rolled back",
+		Statements: statementOutcomes(results),
+		Tables:     tables,
+	}
+}
+
+// executeDryRun - Execute the statements inside a savepoint, read the changes they made and roll everything back.
+// BEGIN, COMMIT and ROLLBACK of the script become a nested savepoint unless opts rejects them.
+func executeDryRun(ctx context.Context, conn *sql.Conn, database *Database, stmts []sqlStatement, params *queryParams, opts executeOptions, encoder *valueEncoder) ([]statementResult, []tableChanges, error) {
+	opts.capture = &changeCapture{authorizer: database.Authorizer}
+	opts.savepointTransactions = true
+
+	if _, err := conn.ExecContext(ctx, "SAVEPOINT "+dryRunSavepoint); err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err := rollbackDryRun(conn); err != nil {
+			zap.S().Errorw("failed to roll back dry run", "error", err)
+		}
+	}()
+
+	results, err := executeStatements(ctx, conn, stmts, params, opts)
+	if err != nil {
+		return results, nil, err
+	}
+	tables, err := opts.capture.changes(ctx, conn, encoder)
+	if err != nil {
+		return results, nil, err
+	}
+	return results, tables, nil
+}
+
+// rollbackDryRun - Undo a dry run by rolling back to its savepoint and releasing it
+func rollbackDryRun(conn *sql.Conn) error {
+	ctx := context.Background()
+	if _, err := conn.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+dryRunSavepoint); err != nil {
+		// An interrupted statement makes SQLite roll back the whole transaction, savepoint included
+		if connAutoCommit(conn) {
+			return nil
+		}
+		return err
+	}
+	_, err := conn.ExecContext(ctx, "RELEASE SAVEPOINT "+dryRunSavepoint)
+	return err
+}
@@ -0,0 +1,175 @@
+package tools
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cnosuke/mcp-sqlite/config"
+)
+
+// SQLite storage classes reported for result columns
+const (
+	StorageNull    = "NULL"
+	StorageInteger = "INTEGER"
+	StorageReal    = "REAL"
+	StorageText    = "TEXT"
+	StorageBlob    = "BLOB"
+	// StorageDatetime marks values the driver decoded from a DATETIME, DATE or
+	// TIMESTAMP column; SQLite stores them as TEXT or INTEGER
+	StorageDatetime = "DATETIME"
+)
+
+// encodedBlob - Type-tagged BLOB value, the same shape read_query and write_query accept as params
+type encodedBlob struct {
+	Type   string `json:"type"`
+	Base64 string `json:"base64,omitempty"`
+	Hex    string `json:"hex,omitempty"`
+}
+
+// text - The encoded bytes without the type tag, used by the text formats
+func (b encodedBlob) text() string {
+	if b.Hex != "" {
+		return b.Hex
+	}
+	return b.Base64
+}
+
+// columnType - Declared type and observed storage classes of a result column
+type columnType struct {
+	Name         string `json:"name"`
+	DeclaredType string `json:"declared_type"`
+	// StorageClass is the storage class of the column's values in this page; mixed classes are joined with "|"
+	StorageClass string `json:"storage_class"`
+}
+
+// valueEncoder - Converts driver values into values that survive JSON and text encoding
+type valueEncoder struct {
+	blobHex        bool
+	bigIntAsString bool
+	timeLayout     string
+	location       *time.Location
+}
+
+// newValueEncoder - Build a value encoder from the query encoding settings
+func newValueEncoder(cfg *config.Config) (*valueEncoder, error) {
+	enc := cfg.Query.Encoding
+	e := &valueEncoder{}
+
+	switch strings.ToLower(enc.Blob) {
+	case "base64", "":
+	case "hex":
+		e.blobHex = true
+	default:
+		return nil, fmt.Errorf("unsupported blob encoding: %s", enc.Blob)
+	}
+
+	switch strings.ToLower(enc.LargeIntegers) {
+	case "number", "":
+	case "string":
+		e.bigIntAsString = true
+	default:
+		return nil, fmt.Errorf("unsupported large integer encoding: %s", enc.LargeIntegers)
+	}
+
+	switch strings.ToLower(enc.TimeFormat) {
+	case "rfc3339", "":
+		e.timeLayout = time.RFC3339
+	case "rfc3339nano":
+		e.timeLayout = time.RFC3339Nano
+	case "sqlite":
+		e.timeLayout = "2006-01-02 15:04:05"
+	default:
+		// Any other value is taken as a Go time layout
+		e.timeLayout = enc.TimeFormat
+	}
+
+	location, err := time.LoadLocation(enc.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", enc.TimeZone, err)
+	}
+	e.location = location
+
+	return e, nil
+}
+
+// encode - Convert a single driver value
+func (e *valueEncoder) encode(val interface{}) interface{} {
+	switch v := val.(type) {
+	case []byte:
+		if e.blobHex {
+			return encodedBlob{Type: "blob", Hex: hex.EncodeToString(v)}
+		}
+		return encodedBlob{Type: "blob", Base64: base64.StdEncoding.EncodeToString(v)}
+	case int64:
+		if e.bigIntAsString && (v > maxSafeJSONInteger || v < -maxSafeJSONInteger) {
+			return strconv.FormatInt(v, 10)
+		}
+		return v
+	case time.Time:
+		return v.In(e.location).Format(e.timeLayout)
+	default:
+		return v
+	}
+}
+
+// storageClass - SQLite storage class of a driver value
+func storageClass(val interface{}) string {
+	switch val.(type) {
+	case nil:
+		return StorageNull
+	case int64, bool:
+		return StorageInteger
+	case float64:
+		return StorageReal
+	case string:
+		return StorageText
+	case []byte:
+		return StorageBlob
+	case time.Time:
+		return StorageDatetime
+	default:
+		return StorageText
+	}
+}
+
+// storageClassTracker - Collects the storage classes seen in each column
+type storageClassTracker struct {
+	seen [][]string
+}
+
+// newStorageClassTracker - Create a tracker for the given number of columns
+func newStorageClassTracker(columns int) *storageClassTracker {
+	return &storageClassTracker{seen: make([][]string, columns)}
+}
+
+// observe - Record the storage classes of one row of driver values
+func (t *storageClassTracker) observe(values []interface{}) {
+	for i, val := range values {
+		class := storageClass(val)
+		if class == StorageNull {
+			continue
+		}
+		found := false
+		for _, c := range t.seen[i] {
+			if c == class {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.seen[i] = append(t.seen[i], class)
+		}
+	}
+}
+
+// class - Storage class summary of column i
+func (t *storageClassTracker) class(i int) string {
+	if len(t.seen[i]) == 0 {
+		return StorageNull
+	}
+	return strings.Join(t.seen[i], "|")
+}
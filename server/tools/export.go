@@ -0,0 +1,404 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Output formats accepted by export_query
+const (
+	ExportCSV = "csv"
+	// ExportExcelCSV is CSV with a byte order mark, CRLF line endings and text cells that Excel would run as formulas escaped
+	ExportExcelCSV = "excel_csv"
+	ExportJSON     = "json"
+	ExportNDJSON   = "ndjson"
+	// ExportSQL is a script of INSERT statements inside one transaction
+	ExportSQL = "sql"
+)
+
+// ExportFormats - All supported export formats
+var ExportFormats = []string{ExportCSV, ExportExcelCSV, ExportJSON, ExportNDJSON, ExportSQL}
+
+// exportExtensions - Export formats by file extension
+var exportExtensions = map[string]string{
+	".csv":    ExportCSV,
+	".json":   ExportJSON,
+	".ndjson": ExportNDJSON,
+	".jsonl":  ExportNDJSON,
+	".sql":    ExportSQL,
+}
+
+// exportMIMETypes - MIME types of the exported files when they are served as resources
+var exportMIMETypes = map[string]string{
+	ExportCSV:      "text/csv",
+	ExportExcelCSV: "text/csv",
+	ExportJSON:     "application/json",
+	ExportNDJSON:   "application/x-ndjson",
+	ExportSQL:      "application/sql",
+}
+
+// exportOptions - How export_query writes the rows
+type exportOptions struct {
+	Format string
+	// Table is the table the INSERT statements of the sql format insert into
+	Table     string
+	Overwrite bool
+}
+
+// exportResult - Response body of export_query
+type exportResult struct {
+	Database string   `json:"database"`
+	Path     string   `json:"path"`
+	Format   string   `json:"format"`
+	Columns  []string `json:"columns"`
+	Rows     int      `json:"rows"`
+	Bytes    int64    `json:"bytes"`
+	SHA256   string   `json:"sha256"`
+	// ResourceURI is set when the file was registered as an MCP resource
+	ResourceURI string  `json:"resource_uri,omitempty"`
+	DurationMS  float64 `json:"duration_ms"`
+}
+
+// allowedExportPath - Resolve an export destination and check that it lies in one of the ExportDirs
+func (d *Database) allowedExportPath(path string) (string, error) {
+	if len(d.ExportDirs) == 0 {
+		return "", fmt.Errorf("exports are disabled for %s; configure export_dirs to allow them", d.Name)
+	}
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if !pathInDirs(resolved, d.ExportDirs) {
+		return "", fmt.Errorf("path %s is outside the allowed directories %v", path, d.ExportDirs)
+	}
+	if database, err := resolvePath(d.Path); err == nil && database == resolved {
+		return "", fmt.Errorf("path %s is the database file itself", path)
+	}
+	return resolved, nil
+}
+
+// exportFormat - The format passed, or the one of the file extension
+func exportFormat(format, path string) (string, error) {
+	if format != "" {
+		format = strings.ToLower(format)
+		for _, f := range ExportFormats {
+			if f == format {
+				return format, nil
+			}
+		}
+		return "", fmt.Errorf("unknown format %q; expected one of %v", format, ExportFormats)
+	}
+	if format, ok := exportExtensions[strings.ToLower(filepath.Ext(path))]; ok {
+		return format, nil
+	}
+	return "", fmt.Errorf("cannot tell the format of %s from its extension; pass format", path)
+}
+
+// exportQuery - Stream the rows of a query to a file in one of the ExportDirs. The rows are written to a
+// temporary file next to the destination, which is renamed into place once the last row was written.
+func (d *Database) exportQuery(ctx context.Context, db queryer, query string, args []any, path string, opts exportOptions, encoder *valueEncoder) (*exportResult, error) {
+	resolved, err := d.allowedExportPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(resolved); err == nil && !opts.Overwrite {
+		return nil, fmt.Errorf("%s already exists; pass overwrite to replace it", path)
+	}
+	temp, err := tempPathFor(resolved)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(temp)
+
+	started := time.Now()
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(temp)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	out := bufio.NewWriter(io.MultiWriter(file, hash))
+
+	writer, err := newExportWriter(out, opts, encoder)
+	if err != nil {
+		return nil, err
+	}
+	result := &exportResult{Database: d.Name, Path: resolved, Format: opts.Format, Columns: columns}
+	if err := writer.header(columns); err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range columns {
+		valuePtrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, err
+		}
+		if err := writer.row(values); err != nil {
+			return nil, err
+		}
+		result.Rows++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := writer.footer(); err != nil {
+		return nil, err
+	}
+	if err := out.Flush(); err != nil {
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(temp, resolved); err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(resolved); err == nil {
+		result.Bytes = info.Size()
+	}
+	result.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	result.DurationMS = float64(time.Since(started).Microseconds()) / 1000
+	return result, nil
+}
+
+// exportWriter - Writes the rows of an export in one format; row gets the values as scanned from the driver
+type exportWriter interface {
+	header(columns []string) error
+	row(values []interface{}) error
+	footer() error
+}
+
+// newExportWriter - The writer of a format
+func newExportWriter(out *bufio.Writer, opts exportOptions, encoder *valueEncoder) (exportWriter, error) {
+	switch opts.Format {
+	case ExportCSV, ExportExcelCSV:
+		w := csv.NewWriter(out)
+		excel := opts.Format == ExportExcelCSV
+		w.UseCRLF = excel
+		return &delimitedExport{out: out, w: w, excel: excel, encoder: encoder}, nil
+	case ExportJSON, ExportNDJSON:
+		return &jsonExport{out: out, array: opts.Format == ExportJSON, encoder: encoder}, nil
+	case ExportSQL:
+		if opts.Table == "" {
+			return nil, fmt.Errorf("table is required for the sql format")
+		}
+		table := quoteIdentifier(opts.Table)
+		if schema, name, ok := parseTableName(opts.Table); ok {
+			table = quoteIdentifier(name)
+			if schema != "" {
+				table = quoteIdentifier(schema) + "." + table
+			}
+		}
+		return &sqlExport{out: out, table: table, encoder: encoder}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q; expected one of %v", opts.Format, ExportFormats)
+}
+
+// delimitedExport - CSV with a header row, formatted like the csv output of read_query
+type delimitedExport struct {
+	out     *bufio.Writer
+	w       *csv.Writer
+	excel   bool
+	encoder *valueEncoder
+	record  []string
+}
+
+// header - Write the header row
+func (e *delimitedExport) header(columns []string) error {
+	e.record = make([]string, len(columns))
+	if !e.excel {
+		return e.w.Write(columns)
+	}
+	// Without the byte order mark Excel reads UTF-8 as the local code page
+	if _, err := e.out.WriteString("\ufeff"); err != nil {
+		return err
+	}
+	for i, column := range columns {
+		e.record[i] = escapeFormula(column)
+	}
+	return e.w.Write(e.record)
+}
+
+// row - Write one row of text cells
+func (e *delimitedExport) row(values []interface{}) error {
+	for i, val := range values {
+		text := formatText(e.encoder.encode(val), "")
+		if _, ok := val.(string); ok && e.excel {
+			text = escapeFormula(text)
+		}
+		e.record[i] = text
+	}
+	return e.w.Write(e.record)
+}
+
+// footer - Flush the CSV writer
+func (e *delimitedExport) footer() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// escapeFormula - Prefix text that a spreadsheet would evaluate as a formula with an apostrophe
+func escapeFormula(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+// jsonExport - One JSON object per row in column order, as an array or as NDJSON
+type jsonExport struct {
+	out     *bufio.Writer
+	array   bool
+	encoder *valueEncoder
+	columns []string
+	encoded []interface{}
+	buf     bytes.Buffer
+	rows    int
+}
+
+// header - Open the array of the json format
+func (e *jsonExport) header(columns []string) error {
+	e.columns = columns
+	e.encoded = make([]interface{}, len(columns))
+	if e.array {
+		return e.out.WriteByte('[')
+	}
+	return nil
+}
+
+// row - Write one row as a JSON object
+func (e *jsonExport) row(values []interface{}) error {
+	for i, val := range values {
+		e.encoded[i] = e.encoder.encode(val)
+	}
+	e.buf.Reset()
+	switch {
+	case !e.array:
+	case e.rows > 0:
+		e.buf.WriteString(",\n")
+	default:
+		e.buf.WriteByte('\n')
+	}
+	if err := writeJSONObject(&e.buf, e.columns, e.encoded); err != nil {
+		return err
+	}
+	if !e.array {
+		e.buf.WriteByte('\n')
+	}
+	e.rows++
+	_, err := e.out.Write(e.buf.Bytes())
+	return err
+}
+
+// footer - Close the array of the json format
+func (e *jsonExport) footer() error {
+	if !e.array {
+		return nil
+	}
+	if e.rows > 0 {
+		e.out.WriteByte('\n')
+	}
+	_, err := e.out.WriteString("]\n")
+	return err
+}
+
+// sqlExport - An INSERT statement per row, wrapped in a transaction
+type sqlExport struct {
+	out     *bufio.Writer
+	table   string
+	encoder *valueEncoder
+	prefix  string
+}
+
+// header - Begin the transaction and build the INSERT prefix
+func (e *sqlExport) header(columns []string) error {
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = quoteIdentifier(column)
+	}
+	e.prefix = fmt.Sprintf("INSERT INTO %s (%s) VALUES (", e.table, strings.Join(names, ", "))
+	_, err := e.out.WriteString("BEGIN TRANSACTION;\n")
+	return err
+}
+
+// row - Write one INSERT statement
+func (e *sqlExport) row(values []interface{}) error {
+	e.out.WriteString(e.prefix)
+	for i, val := range values {
+		if i > 0 {
+			e.out.WriteString(", ")
+		}
+		e.out.WriteString(e.literal(val))
+	}
+	_, err := e.out.WriteString(");\n")
+	return err
+}
+
+// footer - Commit the transaction
+func (e *sqlExport) footer() error {
+	_, err := e.out.WriteString("COMMIT;\n")
+	return err
+}
+
+// literal - An SQL literal that inserts the value with the same storage class
+func (e *sqlExport) literal(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return "NULL"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "NULL"
+		case math.IsInf(v, 1):
+			return "9e999"
+		case math.IsInf(v, -1):
+			return "-9e999"
+		}
+		text := strconv.FormatFloat(v, 'g', -1, 64)
+		// A float without a point or exponent would be inserted as an INTEGER
+		if !strings.ContainsAny(text, ".e") {
+			text += ".0"
+		}
+		return text
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case []byte:
+		return "X'" + hex.EncodeToString(v) + "'"
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	default:
+		// Timestamps are written in the configured time format
+		return e.literal(formatText(e.encoder.encode(v), ""))
+	}
+}
@@ -0,0 +1,315 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cnosuke/mcp-sqlite/config"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// ExportQueryArgs - Arguments for export_query tool (kept for testing compatibility)
+type ExportQueryArgs struct {
+	Query       string `json:"query" jsonschema:"description=The read-only SQL query whose rows are exported"`
+	Params      any    `json:"params,omitempty" jsonschema:"description=Optional positional (array) or named (object) bind parameters"`
+	Path        string `json:"path" jsonschema:"description=Destination file inside one of the allowed export directories"`
+	Format      string `json:"format,omitempty" jsonschema:"description=csv, excel_csv, json, ndjson or sql"`
+	Table       string `json:"table,omitempty" jsonschema:"description=Table the INSERT statements of the sql format insert into"`
+	Overwrite   bool   `json:"overwrite,omitempty" jsonschema:"description=Replace an existing file at path"`
+	Resource    bool   `json:"resource,omitempty" jsonschema:"description=Register the file as an MCP resource"`
+	TimeoutMS   int    `json:"timeout_ms,omitempty" jsonschema:"description=Timeout in milliseconds"`
+	Database    string `json:"database,omitempty" jsonschema:"description=Name of the database to use"`
+	Transaction string `json:"transaction,omitempty" jsonschema:"description=Handle returned by begin_transaction"`
+}
+
+// exportResources - The resources registered for exported files, each removed once its TTL runs out
+type exportResources struct {
+	mcpServer *server.MCPServer
+	ttl       time.Duration
+	maxBytes  int64
+
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+}
+
+// add - Register an exported file as a resource that reads the file when it is requested. Within a client
+// session that supports it, only that session lists and reads the resource.
+func (r *exportResources) add(ctx context.Context, result *exportResult) (string, error) {
+	uri := (&url.URL{Scheme: "file", Path: result.Path}).String()
+	mimeType := exportMIMETypes[result.Format]
+	path := result.Path
+	sessionID := sessionIDFromContext(ctx)
+
+	resource := mcp.NewResource(uri, filepath.Base(path),
+		mcp.WithResourceDescription(fmt.Sprintf("%d rows exported from database %s as %s", result.Rows, result.Database, result.Format)),
+		mcp.WithMIMEType(mimeType),
+	)
+	var remove func()
+	handler := func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		zap.S().Debugw("reading resource", "uri", request.Params.URI)
+		text, err := r.read(path, remove)
+		if err != nil {
+			zap.S().Warnw("failed to read exported file", "path", path, "error", err)
+			return nil, err
+		}
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{URI: request.Params.URI, MIMEType: mimeType, Text: text},
+		}, nil
+	}
+
+	if sessionID != "" {
+		err := r.mcpServer.AddSessionResource(sessionID, resource, handler)
+		if err == nil {
+			remove = func() { r.mcpServer.DeleteSessionResources(sessionID, uri) }
+			r.expire(sessionID+" "+uri, remove)
+			return uri, nil
+		}
+		// The stdio session has no resources of its own, and its client is the only one
+		if !errors.Is(err, server.ErrSessionDoesNotSupportResources) {
+			return "", err
+		}
+	}
+	r.mcpServer.AddResource(resource, handler)
+	remove = func() { r.mcpServer.DeleteResources(uri) }
+	r.expire(uri, remove)
+	return uri, nil
+}
+
+// expire - Remove a resource after the TTL, replacing the timer of an earlier export to the same file
+func (r *exportResources) expire(key string, remove func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if timer, ok := r.timers[key]; ok {
+		timer.Stop()
+		delete(r.timers, key)
+	}
+	if r.ttl <= 0 {
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(r.ttl, func() {
+		r.mu.Lock()
+		current := r.timers[key] == timer
+		if current {
+			delete(r.timers, key)
+		}
+		r.mu.Unlock()
+		if current {
+			zap.S().Debugw("removing expired export resource", "resource", key)
+			remove()
+		}
+	})
+	r.timers[key] = timer
+}
+
+// read - Read an exported file up to the size served as a resource; a resource whose file was deleted is removed
+func (r *exportResources) read(path string, remove func()) (string, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		remove()
+		return "", fmt.Errorf("exported file %s no longer exists; its resource was removed", path)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if r.maxBytes > 0 {
+		// Read one byte more to notice a file that grew past the cap
+		reader = io.LimitReader(file, r.maxBytes+1)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	if r.maxBytes > 0 && int64(len(data)) > r.maxBytes {
+		return "", fmt.Errorf("exported file %s is larger than the %d bytes served as a resource; read it from the export directory instead", path, r.maxBytes)
+	}
+	return string(data), nil
+}
+
+// RegisterExportQueryTool - Register the export_query tool
+func RegisterExportQueryTool(mcpServer *server.MCPServer, databases *Databases, cfg *config.Config) error {
+	zap.S().Debug("registering export_query tool")
+
+	encoder, err := newValueEncoder(cfg)
+	if err != nil {
+		return err
+	}
+	resources := &exportResources{
+		mcpServer: mcpServer,
+		ttl:       cfg.Export.ResourceTTL,
+		maxBytes:  cfg.Export.MaxResourceBytes,
+		timers:    make(map[string]*time.Timer),
+	}
+
+	// Define the tool
+	tool := mcp.NewTool("export_query",
+		mcp.WithDescription("Stream the rows of a read-only query to a file inside the configured export_dirs instead of returning them. "+
+			"Returns the path, row count, size and SHA-256 checksum of the file"),
+		mcp.WithString("query",
+			mcp.Description("The read-only SQL query (SELECT, WITH ... SELECT, VALUES, PRAGMA) whose rows are exported"),
+			mcp.Required(),
+		),
+		mcp.WithAny("params",
+			mcp.Description(paramsDescription),
+		),
+		mcp.WithString("path",
+			mcp.Description("Destination file inside one of the allowed export directories"),
+			mcp.Required(),
+		),
+		mcp.WithString("format",
+			mcp.Description("Taken from the file extension (.csv, .json, .ndjson, .jsonl, .sql) when omitted. "+
+				"excel_csv is CSV with a byte order mark and CRLF line endings, with text cells starting with =, +, -, @ prefixed by an apostrophe; "+
+				"json is an array of objects, ndjson one object per line, and sql an INSERT statement per row inside one transaction"),
+			mcp.Enum(ExportFormats...),
+		),
+		mcp.WithString("table",
+			mcp.Description("Table the INSERT statements of the sql format insert into, as name or schema.name. Required for sql"),
+		),
+		mcp.WithBoolean("overwrite",
+			mcp.Description("Replace an existing file at path"),
+		),
+		mcp.WithBoolean("resource",
+			mcp.Description("Also register the file as an MCP resource and return its URI, so the client can read it with resources/read. "+
+				"The resource belongs to the calling session and expires after export.resource_ttl"),
+		),
+		mcp.WithNumber("timeout_ms",
+			mcp.Description(timeoutDescription+". An export that times out leaves no file behind"),
+			mcp.Min(1),
+		),
+		databaseArgument(databases),
+		transactionArgument(),
+	)
+
+	// Add the tool handler
+	mcpServer.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		// Extract query parameter
+		query, ok := request.GetArguments()["query"].(string)
+		if !ok || query == "" {
+			return mcp.NewToolResultError("query parameter is required"), nil
+		}
+		path := request.GetString("path", "")
+		if path == "" {
+			return mcp.NewToolResultError("path parameter is required"), nil
+		}
+		format, err := exportFormat(request.GetString("format", ""), path)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		opts := exportOptions{
+			Format:    format,
+			Table:     request.GetString("table", ""),
+			Overwrite: request.GetBool("overwrite", false),
+		}
+		if format == ExportSQL && opts.Table == "" {
+			return mcp.NewToolResultError("table is required for the sql format"), nil
+		}
+
+		params, err := parseQueryParams(request.GetArguments()["params"])
+		if err != nil {
+			zap.S().Warnw("invalid params for export_query", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		handle := request.GetString("transaction", "")
+		database, err := callDatabase(databases, request.GetString("database", ""), handle, false)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		timeout, err := callTimeout(cfg, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx, cancel := withCallTimeout(ctx, timeout)
+		defer cancel()
+
+		zap.S().Debugw("executing export_query",
+			"database", database.Name,
+			"query", query,
+			"path", path,
+			"format", format,
+			"timeout", timeout)
+
+		// Use one connection for classification and execution
+		conn, release, err := callConn(ctx, databases, database, handle)
+		if err != nil {
+			zap.S().Errorw("failed to get database connection", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		defer release()
+
+		// Ask SQLite whether the statement is a read-only query
+		class, err := classifyStatement(ctx, conn, query)
+		if err != nil {
+			zap.S().Warnw("failed to classify query",
+				"query", query,
+				"error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if !class.IsQuery() {
+			zap.S().Warnw("invalid query type for export_query",
+				"query", query,
+				"kind", statementKindName(class),
+				"read_only", class.ReadOnly)
+			return mcp.NewToolResultError(fmt.Sprintf("export_query only supports read-only queries (SELECT, VALUES, EXPLAIN, PRAGMA), got %s", statementKindName(class))), nil
+		}
+
+		// Match params against the statement's placeholders
+		args, err := params.argsFor(class)
+		if err == nil {
+			err = params.checkAllUsed()
+		}
+		if err != nil {
+			zap.S().Warnw("params do not match query placeholders",
+				"query", query,
+				"error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		result, err := database.exportQuery(ctx, conn, query, args, path, opts, encoder)
+		if err != nil {
+			zap.S().Errorw("failed to export query",
+				"database", database.Name,
+				"path", path,
+				"error", err)
+			return errorResult(ctx, timeout, err), nil
+		}
+		if request.GetBool("resource", false) {
+			uri, err := resources.add(ctx, result)
+			if err != nil {
+				zap.S().Errorw("failed to register export resource", "path", result.Path, "error", err)
+				return mcp.NewToolResultError(fmt.Sprintf("exported %s, but failed to register it as a resource: %v", result.Path, err)), nil
+			}
+			result.ResourceURI = uri
+		}
+		zap.S().Infow("exported query",
+			"database", database.Name,
+			"path", result.Path,
+			"format", format,
+			"rows", result.Rows,
+			"bytes", result.Bytes)
+
+		toolResult, err := jsonToolResult(result)
+		if err != nil {
+			zap.S().Errorw("failed to convert result to JSON", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return toolResult, nil
+	})
+
+	return nil
+}
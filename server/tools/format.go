@@ -0,0 +1,260 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Output formats supported by read_query
+const (
+	FormatJSONObjects  = "json_objects"
+	FormatJSONColumnar = "json_columnar"
+	FormatCSV          = "csv"
+	FormatTSV          = "tsv"
+	FormatMarkdown     = "markdown"
+	FormatNDJSON       = "ndjson"
+)
+
+// OutputFormats - All supported output formats
+var OutputFormats = []string{
+	FormatJSONObjects,
+	FormatJSONColumnar,
+	FormatCSV,
+	FormatTSV,
+	FormatMarkdown,
+	FormatNDJSON,
+}
+
+// IsValidFormat - Check whether the format is supported
+func IsValidFormat(format string) bool {
+	for _, f := range OutputFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// queryPage - One page of query results in column order
+type queryPage struct {
+	Columns     []string
+	ColumnTypes []columnType
+	Rows        [][]interface{}
+	Offset      int
+	Truncated   bool
+	NextCursor  string
+}
+
+// pageMeta - Paging information returned next to the rows
+type pageMeta struct {
+	ColumnTypes []columnType `json:"column_types"`
+	RowCount    int          `json:"row_count"`
+	Offset      int          `json:"offset"`
+	Truncated   bool         `json:"truncated"`
+	NextCursor  string       `json:"next_cursor,omitempty"`
+}
+
+// jsonObjectsResult - Response body of the json_objects format
+type jsonObjectsResult struct {
+	Rows []map[string]interface{} `json:"rows"`
+	pageMeta
+}
+
+// jsonColumnarResult - Response body of the json_columnar format
+type jsonColumnarResult struct {
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+	pageMeta
+}
+
+// meta - Paging information of the page
+func (p *queryPage) meta() pageMeta {
+	return pageMeta{
+		ColumnTypes: p.ColumnTypes,
+		RowCount:    len(p.Rows),
+		Offset:      p.Offset,
+		Truncated:   p.Truncated,
+		NextCursor:  p.NextCursor,
+	}
+}
+
+// objects - Rows of the page as objects keyed by column name
+func (p *queryPage) objects() []map[string]interface{} {
+	objects := make([]map[string]interface{}, 0, len(p.Rows))
+	for _, row := range p.Rows {
+		object := make(map[string]interface{}, len(p.Columns))
+		for i, col := range p.Columns {
+			object[col] = row[i]
+		}
+		objects = append(objects, object)
+	}
+	return objects
+}
+
+// renderQueryPage - Render a page in the requested format.
+// JSON formats embed the paging information; text formats return it as a second JSON content block.
+func renderQueryPage(format string, page *queryPage) (*mcp.CallToolResult, error) {
+	switch format {
+	case FormatJSONObjects:
+		return jsonToolResult(jsonObjectsResult{Rows: page.objects(), pageMeta: page.meta()})
+	case FormatJSONColumnar:
+		rows := page.Rows
+		if rows == nil {
+			rows = [][]interface{}{}
+		}
+		return jsonToolResult(jsonColumnarResult{Columns: page.Columns, Rows: rows, pageMeta: page.meta()})
+	}
+
+	var body string
+	var err error
+	switch format {
+	case FormatCSV:
+		body, err = renderDelimited(page, ',')
+	case FormatTSV:
+		body, err = renderDelimited(page, '\t')
+	case FormatMarkdown:
+		body = renderMarkdown(page)
+	case FormatNDJSON:
+		body, err = renderNDJSON(page)
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := json.Marshal(page.meta())
+	if err != nil {
+		return nil, err
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.NewTextContent(body),
+			mcp.NewTextContent(string(meta)),
+		},
+	}, nil
+}
+
+// jsonToolResult - Marshal a value into a text tool result
+func jsonToolResult(v interface{}) (*mcp.CallToolResult, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+// renderDelimited - Render CSV or TSV with a header row
+func renderDelimited(page *queryPage, comma rune) (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = comma
+
+	if err := w.Write(page.Columns); err != nil {
+		return "", err
+	}
+	record := make([]string, len(page.Columns))
+	for _, row := range page.Rows {
+		for i, val := range row {
+			record[i] = formatText(val, "")
+		}
+		if err := w.Write(record); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	return buf.String(), w.Error()
+}
+
+// renderMarkdown - Render a Markdown table
+func renderMarkdown(page *queryPage) string {
+	var sb strings.Builder
+
+	cells := make([]string, len(page.Columns))
+	for i, col := range page.Columns {
+		cells[i] = escapeMarkdownCell(col)
+	}
+	sb.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	for i := range cells {
+		cells[i] = "---"
+	}
+	sb.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+
+	for _, row := range page.Rows {
+		for i, val := range row {
+			cells[i] = escapeMarkdownCell(formatText(val, "NULL"))
+		}
+		sb.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+
+	return sb.String()
+}
+
+// escapeMarkdownCell - Escape characters that would break a Markdown table cell
+func escapeMarkdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	s = strings.ReplaceAll(s, "\r\n", "<br>")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+// renderNDJSON - Render one JSON object per row, keeping the column order
+func renderNDJSON(page *queryPage) (string, error) {
+	var buf bytes.Buffer
+	for _, row := range page.Rows {
+		if err := writeJSONObject(&buf, page.Columns, row); err != nil {
+			return "", err
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.String(), nil
+}
+
+// writeJSONObject - Write a row as a JSON object with its keys in column order
+func writeJSONObject(buf *bytes.Buffer, columns []string, row []interface{}) error {
+	buf.WriteByte('{')
+	for i, col := range columns {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(col)
+		if err != nil {
+			return err
+		}
+		val, err := json.Marshal(row[i])
+		if err != nil {
+			return err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// formatText - Format a value for the text formats, writing NULL as null
+func formatText(val interface{}, null string) string {
+	switch v := val.(type) {
+	case nil:
+		return null
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case encodedBlob:
+		return v.text()
+	default:
+		return fmt.Sprint(v)
+	}
+}
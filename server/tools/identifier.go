@@ -0,0 +1,187 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errTableNotFound - resolveTable found no table or view of the name
+var errTableNotFound = errors.New("table not found")
+
+// tableRef - A table or view resolved against the schema of a database
+type tableRef struct {
+	// Schema is main, temp or the name of an attached database
+	Schema string
+	// Name is the name as stored in sqlite_master
+	Name string
+	// Type is table or view
+	Type string
+	// SQL is the CREATE statement of the table or view
+	SQL string
+}
+
+// quoted - The schema-qualified, quoted name for use in SQL
+func (t *tableRef) quoted() string {
+	return quoteIdentifier(t.Schema) + "." + quoteIdentifier(t.Name)
+}
+
+// qualifiedName - schema.name for messages and logs, leaving main unqualified
+func (t *tableRef) qualifiedName() string {
+	if t.Schema == "main" {
+		return t.Name
+	}
+	return t.Schema + "." + t.Name
+}
+
+// quoteIdentifier - Quote a name for use as an SQL identifier
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// unquoteIdentifier - Remove the quotes of an identifier quoted with double quotes, backticks or brackets
+func unquoteIdentifier(text string) (string, bool) {
+	if len(text) < 2 {
+		return "", false
+	}
+	switch open, close := text[0], text[len(text)-1]; {
+	case open == '[' && close == ']':
+		return text[1 : len(text)-1], true
+	case (open == '"' || open == '`') && close == open:
+		quote := string(open)
+		inner := text[1 : len(text)-1]
+		// A lone quote inside means the token ended early
+		if strings.Count(strings.ReplaceAll(inner, quote+quote, ""), quote) > 0 {
+			return "", false
+		}
+		return strings.ReplaceAll(inner, quote+quote, quote), true
+	}
+	return "", false
+}
+
+// parseTableName - Split tool input of the form name or schema.name, where each part may be quoted.
+// ok is false when the input is not a valid (qualified) identifier, such as a name containing spaces.
+func parseTableName(input string) (schema, name string, ok bool) {
+	tokens := tokenizeSQL(input)
+
+	// The tokens have to cover the input without gaps, so whitespace and comments are rejected
+	end := 0
+	for _, token := range tokens {
+		if token.Offset != end {
+			return "", "", false
+		}
+		end = token.Offset + len(token.Text)
+	}
+	if end != len(input) {
+		return "", "", false
+	}
+
+	part := func(token sqlToken) (string, bool) {
+		switch token.Kind {
+		case tokenWord:
+			return token.Text, true
+		case tokenQuotedIdent:
+			return unquoteIdentifier(token.Text)
+		}
+		return "", false
+	}
+
+	switch {
+	case len(tokens) == 1:
+		name, ok = part(tokens[0])
+		return "", name, ok && name != ""
+	case len(tokens) == 3 && tokens[1].Kind == tokenPunct && tokens[1].Text == ".":
+		schema, ok = part(tokens[0])
+		if !ok || schema == "" {
+			return "", "", false
+		}
+		name, ok = part(tokens[2])
+		return schema, name, ok && name != ""
+	}
+	return "", "", false
+}
+
+// databaseNames - Names of the databases open on the connection in search order (main, temp, attached)
+func databaseNames(ctx context.Context, db queryer) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM pragma_database_list ORDER BY seq")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// resolveTable - Resolve tool input to an existing table or view before any SQL is built from it.
+// Unqualified names are searched like SQLite does: temp, main, then attached databases.
+// Input that does not parse as an identifier is taken as a literal table name.
+func resolveTable(ctx context.Context, db queryer, input string) (*tableRef, error) {
+	if input == "" {
+		return nil, fmt.Errorf("table name is required")
+	}
+
+	databases, err := databaseNames(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, name, ok := parseTableName(input)
+	if !ok {
+		schema, name = "", input
+	}
+
+	var candidates []string
+	if strings.EqualFold(schema, "temp") {
+		// temp is only listed once the temporary database has been used
+		candidates = []string{"temp"}
+	} else if schema != "" {
+		found := false
+		for _, database := range databases {
+			if strings.EqualFold(database, schema) {
+				candidates = []string{database}
+				found = true
+				break
+			}
+		}
+		if !found {
+			// Not a database name, so the dot may be part of the table name
+			schema, name = "", input
+		}
+	}
+	if schema == "" {
+		// temp is searched first, like unqualified names in SQL
+		candidates = append(candidates, "temp")
+		for _, database := range databases {
+			if database != "temp" {
+				candidates = append(candidates, database)
+			}
+		}
+	}
+
+	for _, database := range candidates {
+		ref := &tableRef{Schema: database}
+		// The schema name was validated against database_list above; the table name is bound
+		err := db.QueryRowContext(ctx,
+			fmt.Sprintf("SELECT name, type, sql FROM %s.sqlite_master WHERE type IN ('table', 'view') AND name = ? COLLATE NOCASE", quoteIdentifier(database)),
+			name).Scan(&ref.Name, &ref.Type, &ref.SQL)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return ref, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", errTableNotFound, input)
+}
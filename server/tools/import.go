@@ -0,0 +1,864 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+)
+
+// Input formats accepted by import_data
+const (
+	ImportCSV    = "csv"
+	ImportTSV    = "tsv"
+	ImportJSON   = "json"
+	ImportNDJSON = "ndjson"
+)
+
+// ImportFormats - All supported input formats
+var ImportFormats = []string{ImportCSV, ImportTSV, ImportJSON, ImportNDJSON}
+
+// importExtensions - Input formats by file extension
+var importExtensions = map[string]string{
+	".csv":    ImportCSV,
+	".tsv":    ImportTSV,
+	".tab":    ImportTSV,
+	".json":   ImportJSON,
+	".ndjson": ImportNDJSON,
+	".jsonl":  ImportNDJSON,
+}
+
+// importBatchRows - Rows inserted by one prepared INSERT statement
+const importBatchRows = 500
+
+// importMaxVariables - SQLite's default SQLITE_MAX_VARIABLE_NUMBER since 3.32, which caps the rows of a batch
+const importMaxVariables = 32766
+
+// importMaxRejections - Rejected rows listed in the result; the count covers all of them
+const importMaxRejections = 100
+
+// Savepoints of an import that runs inside an open transaction, and of each batch
+const (
+	importSavepoint      = `"mcp_sqlite_import"`
+	importBatchSavepoint = `"mcp_sqlite_import_batch"`
+)
+
+// Column types inferred from the input
+const (
+	importInteger = "INTEGER"
+	importReal    = "REAL"
+	importText    = "TEXT"
+)
+
+// importRecord - One row of the input, or the reason it cannot be imported
+type importRecord struct {
+	// Row is the number of the record in the input, not counting a header
+	Row int
+	// Line is the line the record starts on, for CSV, TSV and NDJSON
+	Line int
+	// Values are indexed like importData.Fields; nil is NULL
+	Values []any
+	Err    error
+}
+
+// importData - The parsed input of import_data
+type importData struct {
+	Format  string
+	Fields  []string
+	Records []importRecord
+	// typed is set for JSON input, whose values carry their type; CSV and TSV values are strings
+	typed bool
+}
+
+// importColumn - An input field and the column it is imported into
+type importColumn struct {
+	Field  string `json:"field"`
+	Column string `json:"column"`
+	// Type is the type inferred from the values of the field
+	Type  string `json:"type"`
+	index int
+}
+
+// importRejection - A row that was not imported
+type importRejection struct {
+	Row    int    `json:"row"`
+	Line   int    `json:"line,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// importOptions - Where and how import_data inserts the parsed input
+type importOptions struct {
+	Table string
+	// Create creates the table from the inferred column types when it does not exist
+	Create bool
+	// Columns maps input fields to table columns; fields mapped to "" are skipped
+	Columns map[string]string
+	// MaxRejected rolls back the whole import when more rows are rejected; -1 disables the check
+	MaxRejected int
+}
+
+// importResult - Response body of import_data
+type importResult struct {
+	Database   string            `json:"database"`
+	Table      string            `json:"table"`
+	Created    bool              `json:"created"`
+	Format     string            `json:"format"`
+	Columns    []importColumn    `json:"columns"`
+	Rows       int               `json:"rows"`
+	Imported   int               `json:"imported"`
+	Rejected   int               `json:"rejected"`
+	Rejections []importRejection `json:"rejections,omitempty"`
+	// Error explains why the import was rolled back
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// allowedImportPath - Resolve a file given to import_data and check that it lies in one of the ImportDirs
+func (d *Database) allowedImportPath(path string) (string, error) {
+	if len(d.ImportDirs) == 0 {
+		return "", fmt.Errorf("importing files is disabled for %s; configure import_dirs to allow it, or pass the data as content", d.Name)
+	}
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if !pathInDirs(resolved, d.ImportDirs) {
+		return "", fmt.Errorf("path %s is outside the allowed directories %v", path, d.ImportDirs)
+	}
+	return resolved, nil
+}
+
+// importFormat - The format of the input: the one passed, the one of the file extension, or the one inline content looks like
+func importFormat(format, path, content string) (string, error) {
+	if format != "" {
+		format = strings.ToLower(format)
+		for _, f := range ImportFormats {
+			if f == format {
+				return format, nil
+			}
+		}
+		return "", fmt.Errorf("unknown format %q; expected one of %v", format, ImportFormats)
+	}
+	if path != "" {
+		if format, ok := importExtensions[strings.ToLower(filepath.Ext(path))]; ok {
+			return format, nil
+		}
+		return "", fmt.Errorf("cannot tell the format of %s from its extension; pass format", path)
+	}
+	switch trimmed := strings.TrimLeft(strings.TrimPrefix(content, "\ufeff"), " \t\r\n"); {
+	case strings.HasPrefix(trimmed, "["):
+		return ImportJSON, nil
+	case strings.HasPrefix(trimmed, "{"):
+		return ImportNDJSON, nil
+	}
+	return ImportCSV, nil
+}
+
+// parseImport - Read the input in the given format. With header false, CSV and TSV fields are named column1, column2, ...
+func parseImport(r io.Reader, format string, header bool) (*importData, error) {
+	// A byte order mark would end up in the first field name or break the JSON decoder
+	buffered := bufio.NewReader(r)
+	if bom, err := buffered.Peek(3); err == nil && string(bom) == "\ufeff" {
+		buffered.Discard(3)
+	}
+
+	var data *importData
+	var err error
+	switch format {
+	case ImportCSV:
+		data, err = parseDelimited(buffered, ',', header)
+	case ImportTSV:
+		data, err = parseDelimited(buffered, '\t', header)
+	case ImportJSON:
+		data, err = parseJSONArray(buffered)
+	case ImportNDJSON:
+		data, err = parseNDJSON(buffered)
+	default:
+		return nil, fmt.Errorf("unknown format %q; expected one of %v", format, ImportFormats)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data.Fields) == 0 {
+		return nil, fmt.Errorf("the input has no fields")
+	}
+	data.Format = format
+	return data, nil
+}
+
+// parseDelimited - Read CSV or TSV. Empty fields are NULL, and records with the wrong number of fields are rejected.
+func parseDelimited(r *bufio.Reader, comma rune, header bool) (*importData, error) {
+	var reader delimitedReader = &tsvReader{r: r}
+	if comma != '\t' {
+		csvReader := csv.NewReader(r)
+		csvReader.Comma = comma
+		csvReader.FieldsPerRecord = -1
+		reader = csvRecords{csvReader}
+	}
+
+	data := &importData{}
+	for {
+		record, line, err := reader.read()
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if data.Fields == nil {
+			if header {
+				if data.Fields, err = headerFields(record); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			for i := range record {
+				data.Fields = append(data.Fields, fmt.Sprintf("column%d", i+1))
+			}
+		}
+
+		rec := importRecord{Row: len(data.Records) + 1, Line: line}
+		if len(record) != len(data.Fields) {
+			rec.Err = fmt.Errorf("has %d fields, expected %d", len(record), len(data.Fields))
+		} else {
+			rec.Values = make([]any, len(record))
+			for i, value := range record {
+				if value != "" {
+					rec.Values[i] = value
+				}
+			}
+		}
+		data.Records = append(data.Records, rec)
+	}
+}
+
+// delimitedReader - Reads the records of CSV or TSV with the line each one starts on
+type delimitedReader interface {
+	read() (record []string, line int, err error)
+}
+
+// csvRecords - CSV records as read by encoding/csv, with quoted fields
+type csvRecords struct {
+	reader *csv.Reader
+}
+
+// read - The next record
+func (c csvRecords) read() ([]string, int, error) {
+	record, err := c.reader.Read()
+	if err != nil {
+		return nil, 0, err
+	}
+	line, _ := c.reader.FieldPos(0)
+	return record, line, nil
+}
+
+// tsvReader - TSV records: one per line, with fields split on tabs. TSV has no quoting, so quotes are taken
+// literally and fields cannot contain tabs or newlines. Blank lines are skipped like encoding/csv does.
+type tsvReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+// read - The next record
+func (t *tsvReader) read() ([]string, int, error) {
+	for {
+		text, err := t.r.ReadString('\n')
+		if err != nil && (err != io.EOF || text == "") {
+			return nil, 0, err
+		}
+		t.line++
+		text = strings.TrimSuffix(strings.TrimSuffix(text, "\n"), "\r")
+		if text != "" {
+			return strings.Split(text, "\t"), t.line, nil
+		}
+		if err == io.EOF {
+			return nil, 0, io.EOF
+		}
+	}
+}
+
+// headerFields - Field names from a header record, which must be non-empty and distinct
+func headerFields(record []string) ([]string, error) {
+	seen := make(map[string]string, len(record))
+	for i, name := range record {
+		if name == "" {
+			return nil, fmt.Errorf("field %d of the header is empty", i+1)
+		}
+		// Column names are case-insensitive in SQLite
+		if previous, ok := seen[strings.ToLower(name)]; ok {
+			return nil, fmt.Errorf("the header names field %q twice (as %q and %q)", name, previous, name)
+		}
+		seen[strings.ToLower(name)] = name
+	}
+	return record, nil
+}
+
+// parseJSONArray - Read a JSON array of objects. Elements that are not objects are rejected.
+func parseJSONArray(r io.Reader) (*importData, error) {
+	dec := json.NewDecoder(r)
+	if token, err := dec.Token(); err != nil || token != json.Delim('[') {
+		return nil, fmt.Errorf("JSON input must be an array of objects")
+	}
+
+	data := &importData{typed: true}
+	fields := map[string]int{}
+	for dec.More() {
+		row := len(data.Records) + 1
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		data.Records = append(data.Records, data.jsonRecord(fields, row, 0, raw))
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the JSON array")
+	}
+	data.padRecords()
+	return data, nil
+}
+
+// parseNDJSON - Read one JSON object per line. Blank lines are skipped; lines that are not objects are rejected.
+func parseNDJSON(r *bufio.Reader) (*importData, error) {
+	data := &importData{typed: true}
+	fields := map[string]int{}
+	for line := 1; ; line++ {
+		text, err := r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if trimmed := bytes.TrimSpace(text); len(trimmed) > 0 {
+			data.Records = append(data.Records, data.jsonRecord(fields, len(data.Records)+1, line, trimmed))
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	data.padRecords()
+	return data, nil
+}
+
+// jsonRecord - Turn one JSON object into a record, adding the keys not seen before as fields
+func (data *importData) jsonRecord(fields map[string]int, row, line int, raw []byte) importRecord {
+	rec := importRecord{Row: row, Line: line}
+	keys, values, err := decodeJSONObject(raw)
+	if err != nil {
+		rec.Err = err
+		return rec
+	}
+	rec.Values = make([]any, len(data.Fields))
+	for i, key := range keys {
+		index, ok := fields[key]
+		if !ok {
+			index = len(data.Fields)
+			fields[key] = index
+			data.Fields = append(data.Fields, key)
+			rec.Values = append(rec.Values, nil)
+		}
+		rec.Values[index] = values[i]
+	}
+	return rec
+}
+
+// padRecords - Extend the values of records read before the last field was seen; missing keys are NULL
+func (data *importData) padRecords() {
+	for i := range data.Records {
+		rec := &data.Records[i]
+		if rec.Err == nil && len(rec.Values) < len(data.Fields) {
+			rec.Values = append(rec.Values, make([]any, len(data.Fields)-len(rec.Values))...)
+		}
+	}
+}
+
+// decodeJSONObject - Keys in input order and converted values of a JSON object. A key given twice keeps its last value.
+func decodeJSONObject(raw []byte) ([]string, []any, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if token, err := dec.Token(); err != nil || token != json.Delim('{') {
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("is not a JSON object")
+	}
+
+	var keys []string
+	var values []any
+	index := map[string]int{}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key := token.(string)
+		var raw any
+		if err := dec.Decode(&raw); err != nil {
+			return nil, nil, err
+		}
+		value, err := importJSONValue(raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("key %q: %w", key, err)
+		}
+		if i, ok := index[key]; ok {
+			values[i] = value
+			continue
+		}
+		index[key] = len(keys)
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, nil, fmt.Errorf("has data after the JSON object")
+	}
+	return keys, values, nil
+}
+
+// importJSONValue - The value a JSON value is inserted as. Booleans become 0 or 1, and nested objects and arrays their JSON text.
+func importJSONValue(value any) (any, error) {
+	switch v := value.(type) {
+	case nil, string:
+		return v, nil
+	case bool:
+		if v {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	default:
+		text, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(text), nil
+	}
+}
+
+// parseImportNumber - The int64 or float64 a CSV value spells, or nil. Numbers with leading zeros,
+// such as postal codes, and integers beyond int64 are kept as text.
+func parseImportNumber(s string) any {
+	digits := strings.TrimLeft(s, "+-")
+	if digits == "" || len(s)-len(digits) > 1 {
+		return nil
+	}
+	if len(digits) > 1 && digits[0] == '0' && digits[1] != '.' {
+		return nil
+	}
+	if strings.Trim(digits, "0123456789") == "" {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i
+		}
+		return nil
+	}
+	// ParseFloat also accepts Inf, NaN and hexadecimal floats
+	if strings.Trim(digits, "0123456789.eE+-") != "" {
+		return nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return nil
+}
+
+// inferType - INTEGER when every value of the field is an integer, REAL when every value is a number, TEXT otherwise
+func (data *importData) inferType(field int) string {
+	integer, real, text := false, false, false
+	for _, rec := range data.Records {
+		if rec.Err != nil {
+			continue
+		}
+		value := rec.Values[field]
+		if s, ok := value.(string); ok && !data.typed {
+			value = parseImportNumber(s)
+			if value == nil {
+				text = true
+			}
+		}
+		switch value.(type) {
+		case int64:
+			integer = true
+		case float64:
+			real = true
+		case string:
+			text = true
+		}
+	}
+	switch {
+	case text || (!integer && !real):
+		return importText
+	case real:
+		return importReal
+	}
+	return importInteger
+}
+
+// value - The value bound for a field; CSV values of numeric columns are bound as numbers
+func (data *importData) value(rec *importRecord, column importColumn) any {
+	value := rec.Values[column.index]
+	if s, ok := value.(string); ok && !data.typed && column.Type != importText {
+		if number := parseImportNumber(s); number != nil {
+			return number
+		}
+	}
+	return value
+}
+
+// importColumns - Map the input fields to the columns they are imported into. Without a mapping a field
+// is imported into the column of the same name; existing tables must have a column for every imported field.
+func (data *importData) importColumns(mapping map[string]string, table *tableRef, existing []columnInfo) ([]importColumn, error) {
+	fieldIndex := make(map[string]int, len(data.Fields))
+	for i, field := range data.Fields {
+		fieldIndex[field] = i
+	}
+	for field := range mapping {
+		if _, ok := fieldIndex[field]; !ok {
+			return nil, fmt.Errorf("columns maps %q, which is not a field of the input (fields: %v)", field, data.Fields)
+		}
+	}
+
+	var columns []importColumn
+	var unmatched []string
+	targets := map[string]string{}
+	for i, field := range data.Fields {
+		name := field
+		if mapped, ok := mapping[field]; ok {
+			if mapped == "" {
+				continue
+			}
+			name = mapped
+		}
+
+		if existing != nil {
+			column, ok := findColumn(existing, name)
+			if !ok {
+				unmatched = append(unmatched, field)
+				continue
+			}
+			if column.Generated != "" {
+				return nil, fmt.Errorf("column %s of %s is generated and cannot be imported into", column.Name, table.qualifiedName())
+			}
+			name = column.Name
+		}
+
+		if previous, ok := targets[strings.ToLower(name)]; ok {
+			return nil, fmt.Errorf("fields %q and %q both map to column %s", previous, field, name)
+		}
+		targets[strings.ToLower(name)] = field
+		columns = append(columns, importColumn{Field: field, Column: name, Type: data.inferType(i), index: i})
+	}
+
+	if len(unmatched) > 0 {
+		return nil, fmt.Errorf("fields %v do not match a column of %s; map them to columns with columns, or skip them by mapping them to \"\"", unmatched, table.qualifiedName())
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no fields are left to import")
+	}
+	return columns, nil
+}
+
+// findColumn - Look up a column by name the way SQLite does, ignoring case
+func findColumn(columns []columnInfo, name string) (columnInfo, bool) {
+	for _, column := range columns {
+		if strings.EqualFold(column.Name, name) {
+			return column, true
+		}
+	}
+	return columnInfo{}, false
+}
+
+// importInto - Create the table when asked and insert the records, all in one transaction. On a connection
+// inside an open transaction the import runs in a savepoint, so a failed import leaves the transaction as it was.
+func (data *importData) importInto(ctx context.Context, conn *sql.Conn, opts importOptions) (*importResult, error) {
+	started := time.Now()
+	begin, commit, rollback := []string{"BEGIN IMMEDIATE"}, []string{"COMMIT"}, []string{"ROLLBACK"}
+	if !connAutoCommit(conn) {
+		begin = []string{"SAVEPOINT " + importSavepoint}
+		commit = []string{"RELEASE SAVEPOINT " + importSavepoint}
+		rollback = []string{"ROLLBACK TO SAVEPOINT " + importSavepoint, "RELEASE SAVEPOINT " + importSavepoint}
+	}
+	exec := func(ctx context.Context, statements []string) error {
+		for _, statement := range statements {
+			if _, err := conn.ExecContext(ctx, statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := exec(ctx, begin); err != nil {
+		return nil, err
+	}
+	result, err := data.importRecords(ctx, conn, opts)
+	if err == nil && result.Error == "" {
+		err = exec(ctx, commit)
+	}
+	if err != nil || result.Error != "" {
+		// An interrupted statement makes SQLite roll back the whole transaction itself
+		if !connAutoCommit(conn) {
+			if rollbackErr := exec(context.Background(), rollback); rollbackErr != nil {
+				zap.S().Errorw("failed to roll back import", "error", rollbackErr)
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.DurationMS = float64(time.Since(started).Microseconds()) / 1000
+	return result, nil
+}
+
+// importRecords - The body of importInto, running inside its transaction
+func (data *importData) importRecords(ctx context.Context, conn *sql.Conn, opts importOptions) (*importResult, error) {
+	result := &importResult{Format: data.Format, Rows: len(data.Records)}
+
+	table, err := resolveTable(ctx, conn, opts.Table)
+	switch {
+	case errors.Is(err, errTableNotFound) && opts.Create:
+		if table, err = data.createTable(ctx, conn, opts); err != nil {
+			return nil, err
+		}
+		result.Created = true
+	case errors.Is(err, errTableNotFound):
+		return nil, fmt.Errorf("%w; pass create_table to create it from the input", err)
+	case err != nil:
+		return nil, err
+	case table.Type != "table":
+		return nil, fmt.Errorf("%s is a %s; import_data inserts into tables", table.qualifiedName(), table.Type)
+	}
+	result.Table = table.qualifiedName()
+
+	existing, err := tableColumns(ctx, conn, table)
+	if err != nil {
+		return nil, err
+	}
+	if result.Columns, err = data.importColumns(opts.Columns, table, existing); err != nil {
+		return nil, err
+	}
+
+	inserter := &importInserter{conn: conn, table: table, columns: result.Columns, data: data, result: result, maxRejected: opts.MaxRejected}
+	defer inserter.close()
+	if err := inserter.insertAll(ctx); err != nil {
+		if !errors.Is(err, errTooManyRejected) {
+			return nil, err
+		}
+		result.Error = fmt.Sprintf("rejected %d rows, more than max_rejected (%d); the import was rolled back", result.Rejected, opts.MaxRejected)
+		result.Imported = 0
+	}
+
+	sort.Slice(result.Rejections, func(i, j int) bool { return result.Rejections[i].Row < result.Rejections[j].Row })
+	if len(result.Rejections) > importMaxRejections {
+		result.Rejections = result.Rejections[:importMaxRejections]
+	}
+	return result, nil
+}
+
+// createTable - Create the table with a column of the inferred type for every field.
+// Fields skipped by the mapping are left out, and mapped fields get the mapped name.
+func (data *importData) createTable(ctx context.Context, conn *sql.Conn, opts importOptions) (*tableRef, error) {
+	schema, name, ok := parseTableName(opts.Table)
+	if !ok {
+		return nil, fmt.Errorf("invalid table name: %s", opts.Table)
+	}
+	columns, err := data.importColumns(opts.Columns, &tableRef{Schema: schema, Name: name}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions := make([]string, len(columns))
+	for i, column := range columns {
+		definitions[i] = quoteIdentifier(column.Column) + " " + column.Type
+	}
+	qualified := quoteIdentifier(name)
+	if schema != "" {
+		qualified = quoteIdentifier(schema) + "." + qualified
+	}
+	statement := fmt.Sprintf("CREATE TABLE %s (%s)", qualified, strings.Join(definitions, ", "))
+	zap.S().Debugw("creating table for import", "query", statement)
+	if _, err := conn.ExecContext(ctx, statement); err != nil {
+		return nil, err
+	}
+	return resolveTable(ctx, conn, opts.Table)
+}
+
+// errTooManyRejected - More rows were rejected than max_rejected allows
+var errTooManyRejected = errors.New("too many rejected rows")
+
+// importInserter - Inserts records in batches with prepared multi-row INSERT statements
+type importInserter struct {
+	conn        *sql.Conn
+	table       *tableRef
+	columns     []importColumn
+	data        *importData
+	result      *importResult
+	maxRejected int
+
+	// statements are the prepared INSERT statements by number of rows
+	statements map[int]*sql.Stmt
+}
+
+// insertAll - Insert every record that parsed, rejecting the others
+func (ins *importInserter) insertAll(ctx context.Context) error {
+	batchRows := importBatchRows
+	if limit := importMaxVariables / len(ins.columns); limit < batchRows {
+		batchRows = limit
+	}
+
+	batch := make([]*importRecord, 0, batchRows)
+	for i := range ins.data.Records {
+		rec := &ins.data.Records[i]
+		if rec.Err != nil {
+			if err := ins.reject(rec, rec.Err); err != nil {
+				return err
+			}
+			continue
+		}
+		batch = append(batch, rec)
+		if len(batch) == batchRows {
+			if err := ins.insertBatch(ctx, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		return ins.insertBatch(ctx, batch)
+	}
+	return nil
+}
+
+// insertBatch - Insert the records with one statement. A batch that violates a constraint is rolled back
+// to its savepoint and retried row by row, so that only the offending rows are rejected.
+func (ins *importInserter) insertBatch(ctx context.Context, batch []*importRecord) error {
+	if len(batch) == 1 {
+		err := ins.exec(ctx, batch)
+		if err == nil || !rowError(err) {
+			return err
+		}
+		if connAutoCommit(ins.conn) {
+			return fmt.Errorf("row %d: %w; a ROLLBACK conflict clause ended the transaction", batch[0].Row, err)
+		}
+		return ins.reject(batch[0], err)
+	}
+
+	// ON CONFLICT FAIL keeps the rows a statement inserted before the failing one, so batches run in a savepoint
+	if _, err := ins.conn.ExecContext(ctx, "SAVEPOINT "+importBatchSavepoint); err != nil {
+		return err
+	}
+	err := ins.exec(ctx, batch)
+	if err != nil && rowError(err) && connAutoCommit(ins.conn) {
+		return fmt.Errorf("%w; a ROLLBACK conflict clause ended the transaction", err)
+	}
+	if err != nil && rowError(err) {
+		if _, err := ins.conn.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+importBatchSavepoint); err != nil {
+			return err
+		}
+	}
+	if _, releaseErr := ins.conn.ExecContext(ctx, "RELEASE SAVEPOINT "+importBatchSavepoint); releaseErr != nil && err == nil {
+		return releaseErr
+	}
+	if err == nil || !rowError(err) {
+		return err
+	}
+
+	zap.S().Debugw("retrying import batch row by row", "rows", len(batch), "error", err)
+	for _, rec := range batch {
+		if err := ins.insertBatch(ctx, []*importRecord{rec}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exec - Run the prepared INSERT statement for the records
+func (ins *importInserter) exec(ctx context.Context, batch []*importRecord) error {
+	stmt, err := ins.statement(ctx, len(batch))
+	if err != nil {
+		return err
+	}
+	args := make([]any, 0, len(batch)*len(ins.columns))
+	for _, rec := range batch {
+		for _, column := range ins.columns {
+			args = append(args, ins.data.value(rec, column))
+		}
+	}
+	if _, err := stmt.ExecContext(ctx, args...); err != nil {
+		return err
+	}
+	ins.result.Imported += len(batch)
+	return nil
+}
+
+// statement - The prepared INSERT statement for a number of rows
+func (ins *importInserter) statement(ctx context.Context, rows int) (*sql.Stmt, error) {
+	if stmt, ok := ins.statements[rows]; ok {
+		return stmt, nil
+	}
+	names := make([]string, len(ins.columns))
+	for i, column := range ins.columns {
+		names[i] = quoteIdentifier(column.Column)
+	}
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(ins.columns)), ", ") + ")"
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s",
+		ins.table.quoted(), strings.Join(names, ", "), strings.TrimSuffix(strings.Repeat(row+", ", rows), ", "))
+
+	stmt, err := ins.conn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if ins.statements == nil {
+		ins.statements = make(map[int]*sql.Stmt)
+	}
+	ins.statements[rows] = stmt
+	return stmt, nil
+}
+
+// reject - Record a rejected row, failing once more rows were rejected than allowed
+func (ins *importInserter) reject(rec *importRecord, reason error) error {
+	ins.result.Rejected++
+	ins.result.Rejections = append(ins.result.Rejections, importRejection{Row: rec.Row, Line: rec.Line, Reason: reason.Error()})
+	if ins.maxRejected >= 0 && ins.result.Rejected > ins.maxRejected {
+		return errTooManyRejected
+	}
+	return nil
+}
+
+// close - Close the prepared statements
+func (ins *importInserter) close() {
+	for _, stmt := range ins.statements {
+		stmt.Close()
+	}
+}
+
+// rowError - Check whether an insert failed because of the values of a row rather than the database
+func rowError(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	switch sqliteErr.Code {
+	case sqlite3.ErrConstraint, sqlite3.ErrMismatch, sqlite3.ErrTooBig:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cnosuke/mcp-sqlite/config"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// ImportDataArgs - Arguments for import_data tool (kept for testing compatibility)
+type ImportDataArgs struct {
+	Table       string            `json:"table" jsonschema:"description=Table to insert into, as name or schema.name"`
+	Content     string            `json:"content,omitempty" jsonschema:"description=The data to import"`
+	Path        string            `json:"path,omitempty" jsonschema:"description=File inside one of the allowed import directories"`
+	Format      string            `json:"format,omitempty" jsonschema:"description=csv, tsv, json or ndjson"`
+	Header      *bool             `json:"header,omitempty" jsonschema:"description=Whether the first CSV or TSV record names the fields"`
+	CreateTable bool              `json:"create_table,omitempty" jsonschema:"description=Create the table from the inferred column types when it does not exist"`
+	Columns     map[string]string `json:"columns,omitempty" jsonschema:"description=Maps input fields to table columns"`
+	MaxRejected *int              `json:"max_rejected,omitempty" jsonschema:"description=Roll back the import when more rows are rejected"`
+	TimeoutMS   int               `json:"timeout_ms,omitempty" jsonschema:"description=Timeout in milliseconds"`
+	Database    string            `json:"database,omitempty" jsonschema:"description=Name of the database to use"`
+	Transaction string            `json:"transaction,omitempty" jsonschema:"description=Handle returned by begin_transaction"`
+}
+
+// importColumnsArgument - The columns argument as a field to column map
+func importColumnsArgument(raw any) (map[string]string, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	object, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("columns must be an object mapping input fields to column names, got %T", raw)
+	}
+	mapping := make(map[string]string, len(object))
+	for field, value := range object {
+		column, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("columns must map %q to a column name, got %T", field, value)
+		}
+		mapping[field] = column
+	}
+	return mapping, nil
+}
+
+// readImport - Parse the inline content or the file of an import_data call
+func readImport(database *Database, content, path, format string, header bool) (*importData, error) {
+	switch {
+	case content != "" && path != "":
+		return nil, fmt.Errorf("pass either content or path, not both")
+	case content == "" && path == "":
+		return nil, fmt.Errorf("content or path is required")
+	}
+
+	format, err := importFormat(format, path, content)
+	if err != nil {
+		return nil, err
+	}
+	var input io.Reader = strings.NewReader(content)
+	if path != "" {
+		resolved, err := database.allowedImportPath(path)
+		if err != nil {
+			return nil, err
+		}
+		file, err := os.Open(resolved)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		input = file
+	}
+	return parseImport(input, format, header)
+}
+
+// RegisterImportDataTool - Register the import_data tool
+func RegisterImportDataTool(mcpServer *server.MCPServer, databases *Databases, cfg *config.Config) error {
+	zap.S().Debug("registering import_data tool")
+
+	// Define the tool
+	tool := mcp.NewTool("import_data",
+		mcp.WithDescription("Import CSV, TSV, a JSON array of objects or NDJSON into a table, passed inline as content or as a file from the configured import_dirs. "+
+			"Column types are inferred from the values, and the rows are inserted in batches of prepared statements inside one transaction. "+
+			"Rows that do not parse or violate a constraint are rejected with their reason while the others are imported"),
+		mcp.WithString("table",
+			mcp.Description("Table to insert into, as name or schema.name"),
+			mcp.Required(),
+		),
+		mcp.WithString("content",
+			mcp.Description("The data to import. Pass either content or path"),
+		),
+		mcp.WithString("path",
+			mcp.Description("File inside one of the allowed import directories. Pass either content or path"),
+		),
+		mcp.WithString("format",
+			mcp.Description("Format of the input. Taken from the file extension (.csv, .tsv, .json, .ndjson, .jsonl) when omitted; inline content starting with [ is json, with { ndjson, anything else csv"),
+			mcp.Enum(ImportFormats...),
+		),
+		mcp.WithBoolean("header",
+			mcp.Description("Whether the first CSV or TSV record names the fields (default true). Without a header the fields are named column1, column2, ..."),
+		),
+		mcp.WithBoolean("create_table",
+			mcp.Description("Create the table when it does not exist, with an INTEGER, REAL or TEXT column per field as inferred from the values"),
+		),
+		mcp.WithObject("columns",
+			mcp.Description("Maps input fields to table columns, e.g. {\"Full Name\": \"name\"}. Fields mapped to \"\" are skipped; other fields are imported into the column of the same name"),
+			mcp.AdditionalProperties(map[string]any{"type": "string"}),
+		),
+		mcp.WithNumber("max_rejected",
+			mcp.Description("Roll back the whole import when more rows than this are rejected; 0 imports all rows or none. Unlimited when omitted"),
+			mcp.Min(0),
+		),
+		mcp.WithNumber("timeout_ms",
+			mcp.Description(timeoutDescription+". An import that times out is rolled back"),
+			mcp.Min(1),
+		),
+		databaseArgument(databases),
+		transactionArgument(),
+	)
+
+	// Add the tool handler
+	mcpServer.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		table := request.GetString("table", "")
+		if table == "" {
+			return mcp.NewToolResultError("table parameter is required"), nil
+		}
+		mapping, err := importColumnsArgument(request.GetArguments()["columns"])
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		opts := importOptions{
+			Table:       table,
+			Create:      request.GetBool("create_table", false),
+			Columns:     mapping,
+			MaxRejected: request.GetInt("max_rejected", -1),
+		}
+
+		handle := request.GetString("transaction", "")
+		database, err := callDatabase(databases, request.GetString("database", ""), handle, true)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		timeout, err := callTimeout(cfg, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx, cancel := withCallTimeout(ctx, timeout)
+		defer cancel()
+
+		path := request.GetString("path", "")
+		zap.S().Debugw("executing import_data",
+			"database", database.Name,
+			"table", table,
+			"path", path,
+			"transaction", handle,
+			"timeout", timeout)
+
+		// The input is parsed before a connection is taken, so no transaction waits on it
+		data, err := readImport(database, request.GetString("content", ""), path, request.GetString("format", ""), request.GetBool("header", true))
+		if err != nil {
+			zap.S().Warnw("failed to read import data", "path", path, "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		conn, release, err := callConn(ctx, databases, database, handle)
+		if err != nil {
+			zap.S().Errorw("failed to get database connection", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		defer release()
+
+		result, err := data.importInto(ctx, conn, opts)
+		if err != nil {
+			zap.S().Errorw("failed to import data",
+				"database", database.Name,
+				"table", table,
+				"error", err)
+			return errorResult(ctx, timeout, err), nil
+		}
+		result.Database = database.Name
+		zap.S().Infow("imported data",
+			"database", database.Name,
+			"table", result.Table,
+			"created", result.Created,
+			"imported", result.Imported,
+			"rejected", result.Rejected)
+
+		toolResult, err := jsonToolResult(result)
+		if err != nil {
+			zap.S().Errorw("failed to convert result to JSON", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		toolResult.IsError = result.Error != ""
+		return toolResult, nil
+	})
+
+	return nil
+}
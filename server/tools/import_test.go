@@ -0,0 +1,231 @@
+package tools
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseImportNumber(t *testing.T) {
+	tests := []struct {
+		in   string
+		want any
+	}{
+		{"0", int64(0)},
+		{"42", int64(42)},
+		{"-42", int64(-42)},
+		{"+7", int64(7)},
+		{"9223372036854775807", int64(9223372036854775807)},
+		{"1.5", 1.5},
+		{"-0.25", -0.25},
+		{"0.5", 0.5},
+		{".5", 0.5},
+		{"1e3", 1000.0},
+		{"2.5E-2", 0.025},
+		// Leading zeros mark identifiers such as postal codes, which stay text
+		{"007", nil},
+		{"00.5", nil},
+		{"-01", nil},
+		// Integers beyond int64 are not rounded to a REAL
+		{"9223372036854775808", nil},
+		{"", nil},
+		{"-", nil},
+		{"+-1", nil},
+		{" 1", nil},
+		{"1 ", nil},
+		{"1,000", nil},
+		{"1_000", nil},
+		{"0x10", nil},
+		{"Inf", nil},
+		{"NaN", nil},
+		{"1e", nil},
+		{"1.2.3", nil},
+		{"12abc", nil},
+	}
+
+	for _, tt := range tests {
+		if got := parseImportNumber(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseImportNumber(%q) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestImportFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		path    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{name: "explicit", format: "TSV", content: "a,b", want: ImportTSV},
+		{name: "unknown explicit", format: "xml", wantErr: "unknown format"},
+		{name: "csv extension", path: "data/users.CSV", want: ImportCSV},
+		{name: "tab extension", path: "users.tab", want: ImportTSV},
+		{name: "jsonl extension", path: "events.jsonl", want: ImportNDJSON},
+		{name: "explicit beats extension", format: "csv", path: "users.txt", want: ImportCSV},
+		{name: "unknown extension", path: "users.txt", wantErr: "cannot tell the format"},
+		{name: "json array content", content: "\n  [{\"a\": 1}]", want: ImportJSON},
+		{name: "ndjson content", content: "{\"a\": 1}\n{\"a\": 2}", want: ImportNDJSON},
+		{name: "content with byte order mark", content: "\ufeff[{\"a\": 1}]", want: ImportJSON},
+		{name: "csv content", content: "a,b\n1,2", want: ImportCSV},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := importFormat(tt.format, tt.path, tt.content)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("importFormat = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseImport(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		header  bool
+		input   string
+		fields  []string
+		records []importRecord
+		wantErr string
+	}{
+		{
+			name:   "csv with quotes, empty fields and a short record",
+			format: ImportCSV,
+			header: true,
+			input:  "\ufeffid,name\r\n1,\"Doe, \"\"J\"\"\"\n2,\n3\n",
+			fields: []string{"id", "name"},
+			records: []importRecord{
+				{Row: 1, Line: 2, Values: []any{"1", `Doe, "J"`}},
+				{Row: 2, Line: 3, Values: []any{"2", nil}},
+				{Row: 3, Line: 4},
+			},
+		},
+		{
+			name:   "csv without header",
+			format: ImportCSV,
+			input:  "a,b\n",
+			fields: []string{"column1", "column2"},
+			records: []importRecord{
+				{Row: 1, Line: 1, Values: []any{"a", "b"}},
+			},
+		},
+		{
+			name:   "tsv takes quotes literally",
+			format: ImportTSV,
+			header: true,
+			input:  "a\tb\n\"quoted\" text\tx\n\ny\t\"\n",
+			fields: []string{"a", "b"},
+			records: []importRecord{
+				{Row: 1, Line: 2, Values: []any{`"quoted" text`, "x"}},
+				{Row: 2, Line: 4, Values: []any{"y", `"`}},
+			},
+		},
+		{
+			name:   "tsv with crlf and no trailing newline",
+			format: ImportTSV,
+			header: true,
+			input:  "a\tb\r\n1\t\r\n2\t3",
+			fields: []string{"a", "b"},
+			records: []importRecord{
+				{Row: 1, Line: 2, Values: []any{"1", nil}},
+				{Row: 2, Line: 3, Values: []any{"2", "3"}},
+			},
+		},
+		{
+			name:   "json array with nested values and a non-object",
+			format: ImportJSON,
+			input:  `[{"a": 1, "b": true}, {"b": {"x": [1]}, "c": 1.5}, 3]`,
+			fields: []string{"a", "b", "c"},
+			records: []importRecord{
+				{Row: 1, Values: []any{int64(1), int64(1), nil}},
+				{Row: 2, Values: []any{nil, `{"x":[1]}`, 1.5}},
+				{Row: 3},
+			},
+		},
+		{
+			name:   "ndjson skips blank lines",
+			format: ImportNDJSON,
+			input:  "{\"a\": \"x\"}\n\n{\"a\": null}\n",
+			fields: []string{"a"},
+			records: []importRecord{
+				{Row: 1, Line: 1, Values: []any{"x"}},
+				{Row: 2, Line: 3, Values: []any{nil}},
+			},
+		},
+		{
+			name:    "duplicate header",
+			format:  ImportCSV,
+			header:  true,
+			input:   "a,A\n1,2\n",
+			wantErr: "names field",
+		},
+		{
+			name:    "empty input",
+			format:  ImportCSV,
+			header:  true,
+			input:   "",
+			wantErr: "no fields",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := parseImport(strings.NewReader(tt.input), tt.format, tt.header)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(data.Fields, tt.fields) {
+				t.Errorf("fields = %q, want %q", data.Fields, tt.fields)
+			}
+			if len(data.Records) != len(tt.records) {
+				t.Fatalf("got %d records, want %d: %+v", len(data.Records), len(tt.records), data.Records)
+			}
+			for i, want := range tt.records {
+				got := data.Records[i]
+				if want.Values == nil {
+					// A rejected record
+					if got.Err == nil || got.Row != want.Row {
+						t.Errorf("record %d = %+v, want row %d rejected", i, got, want.Row)
+					}
+					continue
+				}
+				got.Err = nil
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("record %d = %#v, want %#v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestInferType(t *testing.T) {
+	data, err := parseImport(strings.NewReader("i,r,t,zip,empty\n1,1,a,01234,\n-2,2.5,3,10115,\n"), ImportCSV, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{importInteger, importReal, importText, importText, importText}
+	for i := range data.Fields {
+		if got := data.inferType(i); got != want[i] {
+			t.Errorf("inferType(%s) = %s, want %s", data.Fields[i], got, want[i])
+		}
+	}
+}
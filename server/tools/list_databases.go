@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// ListDatabasesArgs - Arguments for list_databases tool (kept for testing compatibility)
+type ListDatabasesArgs struct {
+	// No arguments needed
+}
+
+// databaseListEntry - One configured database in the list_databases result
+type databaseListEntry struct {
+	Name        string       `json:"name"`
+	Path        string       `json:"path"`
+	ReadOnly    bool         `json:"read_only"`
+	Default     bool         `json:"default"`
+	Attachments []attachment `json:"attachments"`
+	AttachDirs  []string     `json:"attach_dirs,omitempty"`
+}
+
+// RegisterListDatabasesTool - Register the list_databases tool
+func RegisterListDatabasesTool(mcpServer *server.MCPServer, databases *Databases) error {
+	zap.S().Debug("registering list_databases tool")
+
+	// Define the tool (no parameters needed)
+	tool := mcp.NewTool("list_databases",
+		mcp.WithDescription("List the configured databases that can be passed as the database argument of the other tools, with their attached databases"),
+	)
+
+	// Add the tool handler
+	mcpServer.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		zap.S().Debug("executing list_databases")
+
+		entries := make([]databaseListEntry, 0, len(databases.Names()))
+		for _, name := range databases.Names() {
+			database, _ := databases.Get(name)
+			entries = append(entries, databaseListEntry{
+				Name:     database.Name,
+				Path:     database.Path,
+				ReadOnly: database.ReadOnly,
+				Default:  database.Name == databases.DefaultName(),
+				// Attached schemas can be used as schema.table in every tool
+				Attachments: database.Attachments(),
+				AttachDirs:  database.AttachDirs,
+			})
+		}
+
+		// Convert result to JSON
+		result, err := jsonToolResult(entries)
+		if err != nil {
+			zap.S().Errorw("failed to convert result to JSON", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		return result, nil
+	})
+
+	return nil
+}
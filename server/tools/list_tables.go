@@ -3,65 +3,344 @@ package tools
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
+	"github.com/cnosuke/mcp-sqlite/config"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"go.uber.org/zap"
 )
 
+// Row count modes of list_tables
+const (
+	RowCountNone     = "none"
+	RowCountEstimate = "estimate"
+	RowCountExact    = "exact"
+)
+
 // ListTablesArgs - Arguments for list_tables tool (kept for testing compatibility)
 type ListTablesArgs struct {
-	// No arguments needed
+	Schema    string `json:"schema,omitempty" jsonschema:"description=Only list tables of this database (main, temp or an attached database)"`
+	Type      string `json:"type,omitempty" jsonschema:"description=Only list objects of this type: table, view, virtual or shadow"`
+	Pattern   string `json:"pattern,omitempty" jsonschema:"description=GLOB pattern the table name must match"`
+	Regex     string `json:"regex,omitempty" jsonschema:"description=Regular expression the table name must match"`
+	RowCounts string `json:"row_counts,omitempty" jsonschema:"description=Row count mode: none, estimate or exact"`
+	Limit     int    `json:"limit,omitempty" jsonschema:"description=Maximum number of tables to return"`
+	Offset    int    `json:"offset,omitempty" jsonschema:"description=Number of tables to skip"`
+	TimeoutMS int    `json:"timeout_ms,omitempty" jsonschema:"description=Timeout in milliseconds"`
+}
+
+// tableListEntry - One table, view or virtual table in the list_tables result
+type tableListEntry struct {
+	Schema string `json:"schema"`
+	Name   string `json:"name"`
+	// Type is table, view, virtual or shadow
+	Type        string `json:"type"`
+	ColumnCount int    `json:"column_count"`
+	// RowCount is null when it was not requested or no estimate is available
+	RowCount      *int64 `json:"row_count"`
+	RowCountExact bool   `json:"row_count_exact"`
+	// SizeBytes is the size of the table's b-tree from dbstat, omitted when dbstat is not available
+	SizeBytes *int64 `json:"size_bytes,omitempty"`
+}
+
+// tableListResult - Response body of list_tables
+type tableListResult struct {
+	Tables     []tableListEntry `json:"tables"`
+	Total      int              `json:"total"`
+	Offset     int              `json:"offset"`
+	Truncated  bool             `json:"truncated"`
+	NextOffset int              `json:"next_offset,omitempty"`
+}
+
+// listTableEntries - Tables, views and virtual tables of all databases, ordered by database and name
+func listTableEntries(ctx context.Context, db queryer, schema, tableType, pattern string) ([]tableListEntry, error) {
+	databases, err := databaseNames(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	order := make(map[string]int, len(databases))
+	for i, database := range databases {
+		order[strings.ToLower(database)] = i
+	}
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT schema, name, type, ncol FROM pragma_table_list WHERE name NOT LIKE 'sqlite\_%' ESCAPE '\' `+
+			`AND (?1 = '' OR schema = ?1 COLLATE NOCASE) AND (?2 = '' OR type = ?2) AND (?3 = '' OR name GLOB ?3)`,
+		schema, tableType, pattern)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []tableListEntry{}
+	for rows.Next() {
+		var entry tableListEntry
+		if err := rows.Scan(&entry.Schema, &entry.Name, &entry.Type, &entry.ColumnCount); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := order[strings.ToLower(entries[i].Schema)], order[strings.ToLower(entries[j].Schema)]
+		if a != b {
+			return a < b
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+// tableStats - Fills row counts and sizes of the listed tables, caching per-database lookups
+type tableStats struct {
+	db queryer
+	// stat1 records whether each database has a sqlite_stat1 table
+	stat1 map[string]bool
+	// dbstat is false once the dbstat virtual table turned out to be unavailable
+	dbstat bool
+}
+
+// newTableStats - Create a statistics collector for one list_tables call
+func newTableStats(db queryer) *tableStats {
+	return &tableStats{db: db, stat1: make(map[string]bool), dbstat: true}
+}
+
+// fill - Add the row count and size of one entry
+func (s *tableStats) fill(ctx context.Context, entry *tableListEntry, rowCounts string) error {
+	table := &tableRef{Schema: entry.Schema, Name: entry.Name, Type: entry.Type}
+
+	switch rowCounts {
+	case RowCountExact:
+		var count int64
+		if err := s.db.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM %s", table.quoted())).Scan(&count); err != nil {
+			return fmt.Errorf("failed to count rows of %s: %w", table.qualifiedName(), err)
+		}
+		entry.RowCount = &count
+		entry.RowCountExact = true
+	case RowCountEstimate:
+		estimate, err := s.estimateRows(ctx, table)
+		if err != nil {
+			return err
+		}
+		entry.RowCount = estimate
+	}
+
+	// Views and virtual tables have no b-tree of their own
+	if s.dbstat && (entry.Type == "table" || entry.Type == "shadow") {
+		var size sql.NullInt64
+		err := s.db.QueryRowContext(ctx,
+			"SELECT sum(pgsize) FROM dbstat(?) WHERE name = ?", entry.Schema, entry.Name).Scan(&size)
+		switch {
+		case err != nil && strings.Contains(err.Error(), "no such table"):
+			s.dbstat = false
+		case err != nil:
+			return fmt.Errorf("failed to read size of %s: %w", table.qualifiedName(), err)
+		case size.Valid:
+			entry.SizeBytes = &size.Int64
+		}
+	}
+	return nil
+}
+
+// estimateRows - Row count recorded by ANALYZE in sqlite_stat1, or nil if the table was not analyzed
+func (s *tableStats) estimateRows(ctx context.Context, table *tableRef) (*int64, error) {
+	hasStat1, ok := s.stat1[table.Schema]
+	if !ok {
+		var count int
+		err := s.db.QueryRowContext(ctx,
+			fmt.Sprintf("SELECT count(*) FROM %s.sqlite_master WHERE type = 'table' AND name = 'sqlite_stat1'", quoteIdentifier(table.Schema))).Scan(&count)
+		if err != nil {
+			return nil, err
+		}
+		hasStat1 = count > 0
+		s.stat1[table.Schema] = hasStat1
+	}
+	if !hasStat1 {
+		return nil, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		fmt.Sprintf("SELECT stat FROM %s.sqlite_stat1 WHERE tbl = ?", quoteIdentifier(table.Schema)), table.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// The first number of each stat is the number of rows in the table or index
+	var estimate *int64
+	for rows.Next() {
+		var stat string
+		if err := rows.Scan(&stat); err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(stat)
+		if len(fields) == 0 {
+			continue
+		}
+		n, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if estimate == nil || n > *estimate {
+			estimate = &n
+		}
+	}
+	return estimate, rows.Err()
 }
 
 // RegisterListTablesTools - Register the list_tables tool
-func RegisterListTablesTools(mcpServer *server.MCPServer, db *sql.DB) error {
+func RegisterListTablesTools(mcpServer *server.MCPServer, databases *Databases, cfg *config.Config) error {
 	zap.S().Debug("registering list_tables tool")
 
-	// Define the tool (no parameters needed)
+	// Define the tool
 	tool := mcp.NewTool("list_tables",
-		mcp.WithDescription("Get a list of all tables in the database"),
+		mcp.WithDescription("List the tables, views and virtual tables of the main, temp and attached databases "+
+			"with their type, column count, row count and on-disk size (when the dbstat table is available)"),
+		mcp.WithString("schema",
+			mcp.Description("Only list tables of this database (main, temp or an attached database)"),
+		),
+		mcp.WithString("type",
+			mcp.Description("Only list objects of this type"),
+			mcp.Enum("table", "view", "virtual", "shadow"),
+		),
+		mcp.WithString("pattern",
+			mcp.Description("GLOB pattern the table name must match, e.g. user_*"),
+		),
+		mcp.WithString("regex",
+			mcp.Description("Regular expression (Go RE2 syntax) the table name must match"),
+		),
+		mcp.WithString("row_counts",
+			mcp.Description("estimate (default) reports the row count recorded by ANALYZE, exact counts the rows with SELECT count(*), none skips row counts"),
+			mcp.Enum(RowCountEstimate, RowCountExact, RowCountNone),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum number of tables to return (default %d)", cfg.Query.MaxRows)),
+			mcp.Min(1),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Number of tables to skip; pass next_offset of the previous call to continue"),
+			mcp.Min(0),
+		),
+		mcp.WithNumber("timeout_ms",
+			mcp.Description(timeoutDescription),
+			mcp.Min(1),
+		),
+		databaseArgument(databases),
 	)
 
 	// Add the tool handler
 	mcpServer.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		zap.S().Debug("executing list_tables")
+		schema := request.GetString("schema", "")
+		tableType := request.GetString("type", "")
+		pattern := request.GetString("pattern", "")
+		rowCounts := request.GetString("row_counts", RowCountEstimate)
+		limit := request.GetInt("limit", cfg.Query.MaxRows)
+		offset := request.GetInt("offset", 0)
+
+		if rowCounts != RowCountEstimate && rowCounts != RowCountExact && rowCounts != RowCountNone {
+			return mcp.NewToolResultError(fmt.Sprintf("unsupported row_counts mode: %s", rowCounts)), nil
+		}
+		if limit <= 0 {
+			limit = cfg.Query.MaxRows
+		}
+		if offset < 0 {
+			return mcp.NewToolResultError("offset must not be negative"), nil
+		}
+
+		var nameRegex *regexp.Regexp
+		if expr := request.GetString("regex", ""); expr != "" {
+			var err error
+			nameRegex, err = regexp.Compile(expr)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("invalid regex: %s", err)), nil
+			}
+		}
+
+		database, err := requestDatabase(databases, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		timeout, err := callTimeout(cfg, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx, cancel := withCallTimeout(ctx, timeout)
+		defer cancel()
+
+		zap.S().Debugw("executing list_tables",
+			"schema", schema,
+			"type", tableType,
+			"pattern", pattern,
+			"row_counts", rowCounts,
+			"limit", limit,
+			"offset", offset)
+
+		// Use one connection so that temp and attached databases are seen consistently
+		conn, release, err := database.InspectConn(ctx)
+		if err != nil {
+			zap.S().Errorw("failed to get database connection", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		defer release()
 
 		// Get table list from SQLite system tables
-		const query = "SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%'"
-		zap.S().Debugw("querying for tables", "query", query)
-		rows, err := db.QueryContext(ctx, query)
+		entries, err := listTableEntries(ctx, conn, schema, tableType, pattern)
 		if err != nil {
 			zap.S().Errorw("failed to get table list", "error", err)
-			return mcp.NewToolResultError(err.Error()), nil
+			return errorResult(ctx, timeout, err), nil
+		}
+		if nameRegex != nil {
+			matched := entries[:0]
+			for _, entry := range entries {
+				if nameRegex.MatchString(entry.Name) {
+					matched = append(matched, entry)
+				}
+			}
+			entries = matched
 		}
-		defer rows.Close()
 
-		// Slice to store table names
-		var tables []string
+		result := tableListResult{Total: len(entries), Offset: offset}
+		if offset > len(entries) {
+			offset = len(entries)
+		}
+		result.Tables = entries[offset:]
+		if len(result.Tables) > limit {
+			result.Tables = result.Tables[:limit]
+			result.Truncated = true
+			result.NextOffset = offset + limit
+		}
 
-		// Process each row
-		tableCount := 0
-		for rows.Next() {
-			var tableName string
-			if err := rows.Scan(&tableName); err != nil {
-				zap.S().Errorw("failed to scan table name", "error", err)
-				return mcp.NewToolResultError(err.Error()), nil
+		// Row counts and sizes are only collected for the returned page
+		stats := newTableStats(conn)
+		for i := range result.Tables {
+			if err := stats.fill(ctx, &result.Tables[i], rowCounts); err != nil {
+				zap.S().Errorw("failed to collect table statistics",
+					"table_name", result.Tables[i].Name,
+					"error", err)
+				return errorResult(ctx, timeout, err), nil
 			}
-			tables = append(tables, tableName)
-			tableCount++
 		}
-		zap.S().Debugw("found tables", "count", tableCount, "tables", tables)
+		zap.S().Debugw("found tables",
+			"total", result.Total,
+			"returned", len(result.Tables))
 
 		// Convert result to JSON
-		jsonResult, err := json.Marshal(tables)
+		jsonResult, err := jsonToolResult(result)
 		if err != nil {
 			zap.S().Errorw("failed to convert result to JSON", "error", err)
 			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		return mcp.NewToolResultText(string(jsonResult)), nil
+		return jsonResult, nil
 	})
 
 	return nil
@@ -0,0 +1,227 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cnosuke/mcp-sqlite/config"
+	"github.com/cnosuke/mcp-sqlite/migrate"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// MigrationStatusArgs - Arguments for migration_status tool (kept for testing compatibility)
+type MigrationStatusArgs struct {
+	Database string `json:"database,omitempty" jsonschema:"description=Name of the database to use"`
+}
+
+// ApplyMigrationsArgs - Arguments for apply_migrations tool (kept for testing compatibility)
+type ApplyMigrationsArgs struct {
+	Target          int64  `json:"target,omitempty" jsonschema:"description=Apply the pending migrations up to and including this version; all when omitted"`
+	AllowOutOfOrder bool   `json:"allow_out_of_order,omitempty" jsonschema:"description=Also apply pending migrations numbered below the highest applied one"`
+	TimeoutMS       int    `json:"timeout_ms,omitempty" jsonschema:"description=Timeout in milliseconds"`
+	Database        string `json:"database,omitempty" jsonschema:"description=Name of the database to use"`
+}
+
+// migrationStatusResult - Response body of migration_status
+type migrationStatusResult struct {
+	Database       string                    `json:"database"`
+	Directory      string                    `json:"directory"`
+	CurrentVersion int64                     `json:"current_version"`
+	Pending        int                       `json:"pending"`
+	Migrations     []migrate.MigrationStatus `json:"migrations"`
+}
+
+// appliedMigration - One migration applied by apply_migrations
+type appliedMigration struct {
+	Version    int64   `json:"version"`
+	Name       string  `json:"name"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// applyMigrationsResult - Response body of apply_migrations
+type applyMigrationsResult struct {
+	Database       string             `json:"database"`
+	Applied        []appliedMigration `json:"applied"`
+	CurrentVersion int64              `json:"current_version"`
+	Pending        int                `json:"pending"`
+	// Error explains why the migrations stopped; the ones in applied stay applied
+	Error string `json:"error,omitempty"`
+}
+
+// migrationsDatabase - Resolve the database of a call and check that it has migrations
+func migrationsDatabase(databases *Databases, request mcp.CallToolRequest, writable bool) (*Database, error) {
+	database, err := callDatabase(databases, request.GetString("database", ""), "", writable)
+	if err != nil {
+		return nil, err
+	}
+	if database.MigrationsDir == "" {
+		return nil, fmt.Errorf("database %s has no migrations_dir configured", database.Name)
+	}
+	return database, nil
+}
+
+// countPending - Number of migrations not applied yet, out-of-order ones included
+func countPending(statuses []migrate.MigrationStatus) int {
+	pending := 0
+	for _, status := range statuses {
+		if !migrate.IsApplied(status.State) {
+			pending++
+		}
+	}
+	return pending
+}
+
+// RegisterMigrationTools - Register the migration_status and apply_migrations tools
+func RegisterMigrationTools(mcpServer *server.MCPServer, databases *Databases, cfg *config.Config) error {
+	zap.S().Debug("registering migration_status and apply_migrations tools")
+
+	// Define the status tool
+	statusTool := mcp.NewTool("migration_status",
+		mcp.WithDescription("List the migrations of the database's migrations directory with their state: applied, pending, "+
+			"out_of_order (pending, but numbered below the highest applied migration), "+
+			"modified (the file was edited after it was applied) or missing (applied, but the file is gone)"),
+		databaseArgument(databases),
+	)
+
+	mcpServer.AddTool(statusTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		database, err := migrationsDatabase(databases, request, false)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		zap.S().Debugw("executing migration_status", "database", database.Name, "dir", database.MigrationsDir)
+
+		migrations, err := migrate.Load(database.MigrationsDir)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		conn, release, err := database.InspectConn(ctx)
+		if err != nil {
+			zap.S().Errorw("failed to get database connection", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		defer release()
+
+		statuses, err := migrate.Status(ctx, conn, migrations)
+		if err != nil {
+			zap.S().Errorw("failed to read migration status", "database", database.Name, "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		result, err := jsonToolResult(migrationStatusResult{
+			Database:       database.Name,
+			Directory:      database.MigrationsDir,
+			CurrentVersion: migrate.CurrentVersion(statuses),
+			Pending:        countPending(statuses),
+			Migrations:     statuses,
+		})
+		if err != nil {
+			zap.S().Errorw("failed to convert result to JSON", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return result, nil
+	})
+
+	// apply_migrations writes and is left out when every database is read-only
+	if !databases.AnyWritable() {
+		return nil
+	}
+
+	// Define the apply tool
+	applyTool := mcp.NewTool("apply_migrations",
+		mcp.WithDescription("Apply the pending migrations of the database's migrations directory in version order, each in a transaction of its own, "+
+			"and record them with their checksum in "+migrate.TableName+". Refuses to run while an applied migration file was edited or removed, "+
+			"or while a pending migration is numbered below the highest applied one unless allow_out_of_order is set"),
+		mcp.WithNumber("target",
+			mcp.Description("Apply the pending migrations up to and including this version; all when omitted"),
+			mcp.Min(1),
+		),
+		mcp.WithBoolean("allow_out_of_order",
+			mcp.Description("Also apply pending migrations numbered below the highest applied one, in version order"),
+		),
+		mcp.WithNumber("timeout_ms",
+			mcp.Description(timeoutDescription+". The timeout covers all migrations; the one running is rolled back"),
+			mcp.Min(1),
+		),
+		databaseArgument(databases),
+	)
+
+	mcpServer.AddTool(applyTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		database, err := migrationsDatabase(databases, request, true)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		target := int64(request.GetInt("target", 0))
+		allowOutOfOrder := request.GetBool("allow_out_of_order", false)
+
+		timeout, err := callTimeout(cfg, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx, cancel := withCallTimeout(ctx, timeout)
+		defer cancel()
+
+		zap.S().Debugw("executing apply_migrations",
+			"database", database.Name,
+			"dir", database.MigrationsDir,
+			"target", target,
+			"allow_out_of_order", allowOutOfOrder,
+			"timeout", timeout)
+
+		migrations, err := migrate.Load(database.MigrationsDir)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		conn, release, err := database.Conn(ctx)
+		if err != nil {
+			zap.S().Errorw("failed to get database connection", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		defer release()
+
+		results, applyErr := migrate.Up(ctx, conn, migrations, migrate.UpOptions{Target: target, AllowOutOfOrder: allowOutOfOrder})
+		body := applyMigrationsResult{Database: database.Name, Applied: []appliedMigration{}}
+		for _, r := range results {
+			zap.S().Infow("applied migration",
+				"database", database.Name,
+				"version", r.Version,
+				"name", r.Name,
+				"duration", r.Duration)
+			body.Applied = append(body.Applied, appliedMigration{
+				Version:    r.Version,
+				Name:       r.Name,
+				DurationMS: float64(r.Duration.Microseconds()) / 1000,
+			})
+		}
+		if applyErr != nil {
+			zap.S().Errorw("failed to apply migrations", "database", database.Name, "error", applyErr)
+			if result := cancelledResult(ctx, timeout); result != nil && len(results) == 0 {
+				return result, nil
+			}
+			body.Error = applyErr.Error()
+		}
+
+		// The status is read after a timeout too, so that the result shows what was applied
+		statuses, err := migrate.Status(context.Background(), conn, migrations)
+		if err != nil {
+			zap.S().Errorw("failed to read migration status", "database", database.Name, "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		body.CurrentVersion = migrate.CurrentVersion(statuses)
+		body.Pending = countPending(statuses)
+
+		result, err := jsonToolResult(body)
+		if err != nil {
+			zap.S().Errorw("failed to convert result to JSON", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		result.IsError = applyErr != nil
+		return result, nil
+	})
+
+	return nil
+}
@@ -0,0 +1,213 @@
+package tools
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// paramsDescription - Shared description of the params tool argument
+const paramsDescription = "Optional bind parameters. Use an array for positional placeholders (?, ?NNN) or an object for named placeholders (:name, @name, $name). " +
+	"Values may be null, booleans, numbers or strings, or typed objects such as {\"type\":\"blob\",\"base64\":\"...\"}, {\"type\":\"blob\",\"hex\":\"...\"}, " +
+	"{\"type\":\"integer\",\"value\":\"9007199254740993\"}, {\"type\":\"real\",\"value\":1.5} and {\"type\":\"text\",\"value\":\"...\"}"
+
+// maxSafeJSONInteger - Largest integer a JSON number can carry without losing precision
+const maxSafeJSONInteger = 1 << 53
+
+// queryParams - Bind parameters supplied with a tool call
+type queryParams struct {
+	positional []any
+	named      map[string]any
+	// next is the index of the next positional value to hand out
+	next int
+	// used records the named values consumed by at least one statement
+	used map[string]bool
+}
+
+// parseQueryParams - Parse the params tool argument; nil means no parameters were given
+func parseQueryParams(raw any) (*queryParams, error) {
+	switch v := raw.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		params := &queryParams{}
+		for i, item := range v {
+			value, err := convertParamValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid parameter %d: %w", i+1, err)
+			}
+			params.positional = append(params.positional, value)
+		}
+		return params, nil
+	case map[string]any:
+		params := &queryParams{named: make(map[string]any, len(v)), used: make(map[string]bool)}
+		for key, item := range v {
+			name := strings.TrimLeft(key, ":@$")
+			if name == "" {
+				return nil, fmt.Errorf("invalid parameter name %q", key)
+			}
+			value, err := convertParamValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid parameter %q: %w", key, err)
+			}
+			params.named[name] = value
+		}
+		return params, nil
+	default:
+		return nil, fmt.Errorf("params must be an array or an object, got %T", raw)
+	}
+}
+
+// argsFor - Return the arguments for one statement, consuming positional values in order
+func (p *queryParams) argsFor(class statementClass) ([]any, error) {
+	if p == nil {
+		if class.Params > 0 {
+			return nil, fmt.Errorf("statement has %d placeholders but no params were given", class.Params)
+		}
+		return nil, nil
+	}
+
+	if p.named != nil {
+		if class.Params != len(class.NamedParams) {
+			return nil, fmt.Errorf("statement uses positional placeholders, pass params as an array")
+		}
+		// The driver binds a name to its :name, @name and $name placeholders alike
+		var args []any
+		bound := make(map[string]bool)
+		for _, placeholder := range class.NamedParams {
+			name := placeholder[1:]
+			if bound[name] {
+				continue
+			}
+			value, ok := p.named[name]
+			if !ok {
+				return nil, fmt.Errorf("missing value for parameter %q", placeholder)
+			}
+			bound[name] = true
+			p.used[name] = true
+			args = append(args, sql.Named(name, value))
+		}
+		return args, nil
+	}
+
+	if p.next+class.Params > len(p.positional) {
+		return nil, fmt.Errorf("not enough params: statement needs %d, %d remaining", class.Params, len(p.positional)-p.next)
+	}
+	args := p.positional[p.next : p.next+class.Params]
+	p.next += class.Params
+	return args, nil
+}
+
+// checkAllUsed - Verify that every supplied value was bound to a placeholder
+func (p *queryParams) checkAllUsed() error {
+	if p == nil {
+		return nil
+	}
+
+	if p.named != nil {
+		var unused []string
+		for name := range p.named {
+			if !p.used[name] {
+				unused = append(unused, name)
+			}
+		}
+		if len(unused) > 0 {
+			sort.Strings(unused)
+			return fmt.Errorf("params not used by any placeholder: %s", strings.Join(unused, ", "))
+		}
+		return nil
+	}
+
+	if p.next != len(p.positional) {
+		return fmt.Errorf("too many params: %d given, %d placeholders", len(p.positional), p.next)
+	}
+	return nil
+}
+
+// convertParamValue - Convert a JSON value into a value the SQLite driver can bind
+func convertParamValue(raw any) (any, error) {
+	switch v := raw.(type) {
+	case nil, bool, string:
+		return v, nil
+	case float64:
+		// JSON numbers arrive as float64; keep whole numbers as INTEGER
+		if v == math.Trunc(v) && math.Abs(v) <= maxSafeJSONInteger {
+			return int64(v), nil
+		}
+		return v, nil
+	case map[string]any:
+		return convertTypedParam(v)
+	default:
+		return nil, fmt.Errorf("unsupported value type %T", raw)
+	}
+}
+
+// convertTypedParam - Convert a {"type": ..., ...} parameter object
+func convertTypedParam(obj map[string]any) (any, error) {
+	typeName, _ := obj["type"].(string)
+
+	switch strings.ToLower(typeName) {
+	case "null":
+		return nil, nil
+	case "blob":
+		if encoded, ok := obj["base64"].(string); ok {
+			data, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64 blob: %w", err)
+			}
+			return data, nil
+		}
+		if encoded, ok := obj["hex"].(string); ok {
+			data, err := hex.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("invalid hex blob: %w", err)
+			}
+			return data, nil
+		}
+		return nil, fmt.Errorf("blob parameter requires a base64 or hex field")
+	case "integer":
+		switch value := obj["value"].(type) {
+		case string:
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid integer: %w", err)
+			}
+			return n, nil
+		case float64:
+			if value != math.Trunc(value) {
+				return nil, fmt.Errorf("invalid integer: %v", value)
+			}
+			if math.Abs(value) > maxSafeJSONInteger {
+				// Precision is already lost; larger integers have to be passed as a string
+				return nil, fmt.Errorf("invalid integer: %v is beyond the exact range of a JSON number, pass it as a string", value)
+			}
+			return int64(value), nil
+		}
+		return nil, fmt.Errorf("integer parameter requires a string or number value")
+	case "real":
+		switch value := obj["value"].(type) {
+		case string:
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid real: %w", err)
+			}
+			return f, nil
+		case float64:
+			return value, nil
+		}
+		return nil, fmt.Errorf("real parameter requires a string or number value")
+	case "text":
+		value, ok := obj["value"].(string)
+		if !ok {
+			return nil, fmt.Errorf("text parameter requires a string value")
+		}
+		return value, nil
+	default:
+		return nil, fmt.Errorf("unknown parameter type %q", typeName)
+	}
+}
@@ -0,0 +1,158 @@
+package tools
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConvertParamValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     any
+		want    any
+		wantErr string
+	}{
+		{name: "null", raw: nil, want: nil},
+		{name: "bool", raw: true, want: true},
+		{name: "string", raw: "abc", want: "abc"},
+		{name: "whole number", raw: float64(42), want: int64(42)},
+		{name: "negative whole number", raw: float64(-7), want: int64(-7)},
+		{name: "fraction", raw: 1.5, want: 1.5},
+		{name: "beyond 2^53 stays real", raw: float64(1 << 54), want: float64(1 << 54)},
+		{name: "typed null", raw: map[string]any{"type": "null"}, want: nil},
+		{name: "base64 blob", raw: map[string]any{"type": "blob", "base64": "AP8="}, want: []byte{0x00, 0xff}},
+		{name: "hex blob", raw: map[string]any{"type": "BLOB", "hex": "00ff"}, want: []byte{0x00, 0xff}},
+		{name: "large integer", raw: map[string]any{"type": "integer", "value": "9007199254740993"}, want: int64(9007199254740993)},
+		{name: "integer from number", raw: map[string]any{"type": "integer", "value": float64(3)}, want: int64(3)},
+		{name: "real from string", raw: map[string]any{"type": "real", "value": "2.5"}, want: 2.5},
+		{name: "real from whole number", raw: map[string]any{"type": "real", "value": float64(2)}, want: float64(2)},
+		{name: "text", raw: map[string]any{"type": "text", "value": "12"}, want: "12"},
+		{name: "invalid base64", raw: map[string]any{"type": "blob", "base64": "!"}, wantErr: "invalid base64 blob"},
+		{name: "blob without data", raw: map[string]any{"type": "blob"}, wantErr: "requires a base64 or hex field"},
+		{name: "integer out of range", raw: map[string]any{"type": "integer", "value": "9223372036854775808"}, wantErr: "invalid integer"},
+		{name: "fractional integer", raw: map[string]any{"type": "integer", "value": 1.5}, wantErr: "invalid integer"},
+		{name: "integer number beyond int64", raw: map[string]any{"type": "integer", "value": 1e20}, wantErr: "invalid integer"},
+		{name: "integer number beyond exact range", raw: map[string]any{"type": "integer", "value": float64(1 << 60)}, wantErr: "invalid integer"},
+		{name: "text without string", raw: map[string]any{"type": "text", "value": 1.0}, wantErr: "requires a string value"},
+		{name: "unknown type", raw: map[string]any{"type": "date"}, wantErr: "unknown parameter type"},
+		{name: "array", raw: []any{1.0}, wantErr: "unsupported value type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertParamValue(tt.raw)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("convertParamValue(%v) error = %v, want %q", tt.raw, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("convertParamValue(%v) error = %v", tt.raw, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("convertParamValue(%v) = %#v, want %#v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQueryParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     any
+		wantErr string
+	}{
+		{name: "absent", raw: nil},
+		{name: "positional", raw: []any{1.0, "a"}},
+		{name: "named with prefixes", raw: map[string]any{":a": 1.0, "@b": 2.0, "$c": 3.0, "d": 4.0}},
+		{name: "empty name", raw: map[string]any{":": 1.0}, wantErr: "invalid parameter name"},
+		{name: "invalid positional value", raw: []any{[]any{}}, wantErr: "invalid parameter 1"},
+		{name: "scalar", raw: "a", wantErr: "params must be an array or an object"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseQueryParams(tt.raw)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("parseQueryParams(%v) error = %v", tt.raw, err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("parseQueryParams(%v) error = %v, want %q", tt.raw, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQueryParamsPositional(t *testing.T) {
+	params, err := parseQueryParams([]any{1.0, "a", 2.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Statements of a script consume the values in order
+	args, err := params.argsFor(statementClass{Params: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []any{int64(1), "a"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("first statement args = %#v, want %#v", args, want)
+	}
+	if err := params.checkAllUsed(); err == nil || !strings.Contains(err.Error(), "too many params") {
+		t.Errorf("checkAllUsed with a value left = %v, want too many params", err)
+	}
+	if _, err := params.argsFor(statementClass{Params: 2}); err == nil || !strings.Contains(err.Error(), "not enough params") {
+		t.Errorf("argsFor beyond the values = %v, want not enough params", err)
+	}
+	args, err = params.argsFor(statementClass{Params: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []any{2.5}; !reflect.DeepEqual(args, want) {
+		t.Errorf("second statement args = %#v, want %#v", args, want)
+	}
+	if err := params.checkAllUsed(); err != nil {
+		t.Errorf("checkAllUsed = %v", err)
+	}
+}
+
+func TestQueryParamsNamed(t *testing.T) {
+	params, err := parseQueryParams(map[string]any{":id": 1.0, "name": "a", "unused": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// :id and $id bind the same value once
+	args, err := params.argsFor(statementClass{Params: 3, NamedParams: []string{":id", "@name", "$id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []any{sql.Named("id", int64(1)), sql.Named("name", "a")}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %#v, want %#v", args, want)
+	}
+	if err := params.checkAllUsed(); err == nil || !strings.Contains(err.Error(), "unused") {
+		t.Errorf("checkAllUsed = %v, want unused reported", err)
+	}
+
+	if _, err := params.argsFor(statementClass{Params: 1}); err == nil || !strings.Contains(err.Error(), "positional placeholders") {
+		t.Errorf("positional placeholder with named params = %v", err)
+	}
+	if _, err := params.argsFor(statementClass{Params: 1, NamedParams: []string{":other"}}); err == nil || !strings.Contains(err.Error(), "missing value") {
+		t.Errorf("missing named value = %v", err)
+	}
+}
+
+func TestQueryParamsAbsent(t *testing.T) {
+	var params *queryParams
+	if args, err := params.argsFor(statementClass{}); err != nil || args != nil {
+		t.Errorf("argsFor without placeholders = %v, %v", args, err)
+	}
+	if _, err := params.argsFor(statementClass{Params: 1}); err == nil {
+		t.Error("argsFor with a placeholder and no params succeeded")
+	}
+	if err := params.checkAllUsed(); err != nil {
+		t.Errorf("checkAllUsed = %v", err)
+	}
+}
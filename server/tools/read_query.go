@@ -2,10 +2,11 @@ package tools
 
 import (
 	"context"
-	"database/sql"
+	"encoding/base64"
 	"encoding/json"
-	"strings"
+	"fmt"
 
+	"github.com/cnosuke/mcp-sqlite/config"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"go.uber.org/zap"
@@ -13,46 +14,214 @@ import (
 
 // ReadQueryArgs - Arguments for read_query tool (kept for testing compatibility)
 type ReadQueryArgs struct {
-	Query string `json:"query" jsonschema:"description=The SELECT SQL query to execute"`
+	Query       string `json:"query" jsonschema:"description=The read-only SQL query (SELECT, WITH ... SELECT, VALUES, EXPLAIN, PRAGMA) to execute"`
+	Params      any    `json:"params,omitempty" jsonschema:"description=Optional positional (array) or named (object) bind parameters"`
+	Limit       int    `json:"limit,omitempty" jsonschema:"description=Maximum number of rows to return"`
+	Offset      int    `json:"offset,omitempty" jsonschema:"description=Number of rows to skip"`
+	Cursor      string `json:"cursor,omitempty" jsonschema:"description=Cursor returned as next_cursor by a previous call"`
+	Format      string `json:"format,omitempty" jsonschema:"description=Output format: json_objects, json_columnar, csv, tsv, markdown or ndjson"`
+	TimeoutMS   int    `json:"timeout_ms,omitempty" jsonschema:"description=Timeout in milliseconds after which the query is interrupted"`
+	Transaction string `json:"transaction,omitempty" jsonschema:"description=Handle returned by begin_transaction"`
+}
+
+// readCursor - State carried by an opaque read_query cursor
+type readCursor struct {
+	Database string `json:"d,omitempty"`
+	Query    string `json:"q"`
+	Params   any    `json:"p,omitempty"`
+	Offset   int    `json:"o"`
+	Limit    int    `json:"l,omitempty"`
+	Format   string `json:"f,omitempty"`
+}
+
+// encodeReadCursor - Encode a cursor as an opaque token
+func encodeReadCursor(cursor readCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeReadCursor - Decode an opaque cursor token
+func decodeReadCursor(token string) (readCursor, error) {
+	var cursor readCursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor, fmt.Errorf("invalid cursor")
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Query == "" || cursor.Offset < 0 {
+		return cursor, fmt.Errorf("invalid cursor")
+	}
+	return cursor, nil
 }
 
 // RegisterReadQueryTool - Register the read_query tool
-func RegisterReadQueryTool(mcpServer *server.MCPServer, db *sql.DB) error {
+func RegisterReadQueryTool(mcpServer *server.MCPServer, databases *Databases, cfg *config.Config) error {
 	zap.S().Debug("registering read_query tool")
 
+	if !IsValidFormat(cfg.Query.DefaultFormat) {
+		return fmt.Errorf("unsupported default output format: %s", cfg.Query.DefaultFormat)
+	}
+	encoder, err := newValueEncoder(cfg)
+	if err != nil {
+		return err
+	}
+
 	// Define the tool
 	tool := mcp.NewTool("read_query",
-		mcp.WithDescription("Execute SELECT queries to read data from the database"),
+		mcp.WithDescription("Execute read-only queries (SELECT, WITH ... SELECT, VALUES, EXPLAIN, PRAGMA) to read data from the database. "+
+			"Results are paged: when truncated is true, pass next_cursor as cursor to continue where the previous call stopped"),
 		mcp.WithString("query",
-			mcp.Description("The SELECT SQL query to execute"),
-			mcp.Required(),
+			mcp.Description("The read-only SQL query to execute. Required unless cursor is given"),
+		),
+		mcp.WithAny("params",
+			mcp.Description(paramsDescription),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum number of rows to return (default %d)", cfg.Query.MaxRows)),
+			mcp.Min(1),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Number of rows to skip before returning results"),
+			mcp.Min(0),
 		),
+		mcp.WithString("cursor",
+			mcp.Description("Opaque cursor returned as next_cursor by a previous call; continues the same query"),
+		),
+		mcp.WithString("format",
+			mcp.Description(fmt.Sprintf("Output format (default %s). json_objects returns rows as objects, json_columnar returns ordered columns and row arrays; "+
+				"csv, tsv, markdown and ndjson return the rows as text followed by a JSON block with paging information", cfg.Query.DefaultFormat)),
+			mcp.Enum(OutputFormats...),
+		),
+		mcp.WithNumber("timeout_ms",
+			mcp.Description(timeoutDescription),
+			mcp.Min(1),
+		),
+		databaseArgument(databases),
+		transactionArgument(),
 	)
 
 	// Add the tool handler
 	mcpServer.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		// Extract query parameter
-		query, ok := request.Params.Arguments["query"].(string)
-		if !ok || query == "" {
+		// Extract query parameters, either directly or from a cursor
+		query, _ := request.GetArguments()["query"].(string)
+		rawParams := request.GetArguments()["params"]
+		limit := request.GetInt("limit", 0)
+		offset := request.GetInt("offset", 0)
+		format := request.GetString("format", "")
+		databaseName := request.GetString("database", "")
+		handle := request.GetString("transaction", "")
+
+		if token := request.GetString("cursor", ""); token != "" {
+			cursor, err := decodeReadCursor(token)
+			if err != nil {
+				zap.S().Warnw("invalid cursor for read_query", "error", err)
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			if query != "" && query != cursor.Query {
+				return mcp.NewToolResultError("cursor belongs to a different query"), nil
+			}
+			if databaseName != "" && databaseName != cursor.Database {
+				return mcp.NewToolResultError("cursor belongs to a different database"), nil
+			}
+			databaseName = cursor.Database
+			query = cursor.Query
+			rawParams = cursor.Params
+			offset = cursor.Offset
+			if limit <= 0 {
+				limit = cursor.Limit
+			}
+			if format == "" {
+				format = cursor.Format
+			}
+		}
+
+		if query == "" {
 			return mcp.NewToolResultError("query parameter is required"), nil
 		}
+		if limit <= 0 {
+			limit = cfg.Query.MaxRows
+		}
+		if offset < 0 {
+			return mcp.NewToolResultError("offset must not be negative"), nil
+		}
+		if format == "" {
+			format = cfg.Query.DefaultFormat
+		}
+		if !IsValidFormat(format) {
+			return mcp.NewToolResultError(fmt.Sprintf("unsupported format: %s", format)), nil
+		}
+
+		params, err := parseQueryParams(rawParams)
+		if err != nil {
+			zap.S().Warnw("invalid params for read_query", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		database, err := callDatabase(databases, databaseName, handle, false)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		timeout, err := callTimeout(cfg, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx, cancel := withCallTimeout(ctx, timeout)
+		defer cancel()
+
+		zap.S().Debugw("executing read_query",
+			"database", database.Name,
+			"query", query,
+			"limit", limit,
+			"offset", offset,
+			"timeout", timeout)
+
+		// Use one connection for classification and execution
+		conn, release, err := callConn(ctx, databases, database, handle)
+		if err != nil {
+			zap.S().Errorw("failed to get database connection", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		defer release()
 
-		zap.S().Debugw("executing read_query", "query", query)
+		// Ask SQLite whether the statement is a read-only query
+		class, err := classifyStatement(ctx, conn, query)
+		if err != nil {
+			zap.S().Warnw("failed to classify query",
+				"query", query,
+				"error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if !class.IsQuery() {
+			zap.S().Warnw("invalid query type for read_query",
+				"query", query,
+				"kind", statementKindName(class),
+				"read_only", class.ReadOnly)
+			return mcp.NewToolResultError(fmt.Sprintf("read_query only supports read-only queries (SELECT, VALUES, EXPLAIN, PRAGMA), got %s", statementKindName(class))), nil
+		}
 
-		// Verify query starts with SELECT
-		if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(query)), "SELECT") {
-			zap.S().Warnw("invalid query type for read_query", "query", query)
-			return mcp.NewToolResultError("read_query only supports SELECT queries"), nil
+		// Match params against the statement's placeholders
+		args, err := params.argsFor(class)
+		if err == nil {
+			err = params.checkAllUsed()
+		}
+		if err != nil {
+			zap.S().Warnw("params do not match query placeholders",
+				"query", query,
+				"error", err)
+			return mcp.NewToolResultError(err.Error()), nil
 		}
 
 		// Execute query
-		zap.S().Debugw("executing SELECT query", "query", query)
-		rows, err := db.QueryContext(ctx, query)
+		zap.S().Debugw("executing read-only query", "query", query, "kind", class.Kind)
+		rows, err := conn.QueryContext(ctx, query, args...)
 		if err != nil {
 			zap.S().Errorw("failed to execute query",
 				"query", query,
 				"error", err)
-			return mcp.NewToolResultError(err.Error()), nil
+			return errorResult(ctx, timeout, err), nil
 		}
 		defer rows.Close()
 
@@ -64,13 +233,32 @@ func RegisterReadQueryTool(mcpServer *server.MCPServer, db *sql.DB) error {
 		}
 		zap.S().Debugw("query columns", "columns", columns)
 
-		// Slice to store results
-		var results []map[string]interface{}
+		declaredTypes, err := rows.ColumnTypes()
+		if err != nil {
+			zap.S().Errorw("failed to get column types", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		classes := newStorageClassTracker(len(columns))
+
+		// Skip rows before the requested offset
+		skipped := 0
+		for skipped < offset && rows.Next() {
+			skipped++
+		}
+
+		page := &queryPage{
+			Columns: columns,
+			Offset:  offset,
+		}
+
+		// Process each row until the row limit or the byte budget is reached
+		totalBytes := 0
+		for skipped == offset && rows.Next() {
+			if len(page.Rows) >= limit {
+				page.Truncated = true
+				break
+			}
 
-		// Process each row
-		rowCount := 0
-		for rows.Next() {
-			rowCount++
 			// Create scan destinations dynamically based on column count
 			values := make([]interface{}, len(columns))
 			valuePtrs := make([]interface{}, len(columns))
@@ -81,35 +269,76 @@ func RegisterReadQueryTool(mcpServer *server.MCPServer, db *sql.DB) error {
 			// Scan the row
 			if err := rows.Scan(valuePtrs...); err != nil {
 				zap.S().Errorw("failed to scan row",
-					"row", rowCount,
+					"row", offset+len(page.Rows)+1,
 					"error", err)
 				return mcp.NewToolResultError(err.Error()), nil
 			}
 
-			// Convert row to map
-			row := make(map[string]interface{})
-			for i, col := range columns {
-				val := values[i]
-				// Convert SQLite values to Go types
-				switch v := val.(type) {
-				case []byte:
-					row[col] = string(v)
-				default:
-					row[col] = v
+			// Encode BLOBs, large integers and timestamps for the response
+			classes.observe(values)
+			for i, val := range values {
+				values[i] = encoder.encode(val)
+			}
+
+			// Always return at least one row so that a cursor can make progress
+			if cfg.Query.MaxBytes > 0 {
+				encoded, err := json.Marshal(values)
+				if err != nil {
+					zap.S().Errorw("failed to convert row to JSON", "error", err)
+					return mcp.NewToolResultError(err.Error()), nil
 				}
+				if len(page.Rows) > 0 && totalBytes+len(encoded) > cfg.Query.MaxBytes {
+					page.Truncated = true
+					break
+				}
+				totalBytes += len(encoded)
+			}
+
+			page.Rows = append(page.Rows, values)
+		}
+		if err := rows.Err(); err != nil {
+			zap.S().Errorw("failed to read rows", "error", err)
+			return errorResult(ctx, timeout, err), nil
+		}
+		page.ColumnTypes = make([]columnType, len(columns))
+		for i, col := range columns {
+			page.ColumnTypes[i] = columnType{
+				Name:         col,
+				DeclaredType: declaredTypes[i].DatabaseTypeName(),
+				StorageClass: classes.class(i),
 			}
-			results = append(results, row)
 		}
-		zap.S().Debugw("query completed", "rows_returned", rowCount)
 
-		// Convert results to JSON
-		jsonResult, err := json.Marshal(results)
+		zap.S().Debugw("query completed",
+			"rows_returned", len(page.Rows),
+			"truncated", page.Truncated)
+
+		// Hand out a cursor that continues after the last returned row
+		if page.Truncated {
+			page.NextCursor, err = encodeReadCursor(readCursor{
+				Database: database.Name,
+				Query:    query,
+				Params:   rawParams,
+				Offset:   offset + len(page.Rows),
+				Limit:    limit,
+				Format:   format,
+			})
+			if err != nil {
+				zap.S().Errorw("failed to encode cursor", "error", err)
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
+
+		// Render results in the requested format
+		result, err := renderQueryPage(format, page)
 		if err != nil {
-			zap.S().Errorw("failed to convert results to JSON", "error", err)
+			zap.S().Errorw("failed to format results",
+				"format", format,
+				"error", err)
 			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		return mcp.NewToolResultText(string(jsonResult)), nil
+		return result, nil
 	})
 
 	return nil
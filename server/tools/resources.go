@@ -0,0 +1,330 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/cnosuke/mcp-sqlite/config"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// Resource URI templates; {database} is the name of a configured database
+const (
+	TableSchemaTemplateURI = "sqlite://{database}/tables/{name}/schema"
+	TableSampleTemplateURI = "sqlite://{database}/tables/{name}/sample"
+)
+
+// SchemaResourceURI - URI of the schema resource of a database
+func SchemaResourceURI(database string) string {
+	return "sqlite://" + url.PathEscape(database) + "/schema"
+}
+
+// resourceSampleRows - Number of rows returned by the table sample resource
+const resourceSampleRows = 5
+
+// maxCompletionValues - Largest number of values a completion response may carry
+const maxCompletionValues = 100
+
+// RegisterAllResources - Register the schema resources of every database and the table resource templates
+func RegisterAllResources(mcpServer *server.MCPServer, databases *Databases, cfg *config.Config) error {
+	zap.S().Debug("registering schema resources")
+
+	encoder, err := newValueEncoder(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Full DDL of each database
+	for _, name := range databases.Names() {
+		database, err := databases.Get(name)
+		if err != nil {
+			return err
+		}
+		mcpServer.AddResource(
+			mcp.NewResource(SchemaResourceURI(name), fmt.Sprintf("Schema of database %s", name),
+				mcp.WithResourceDescription(fmt.Sprintf("CREATE statements of all tables, indexes, views and triggers in database %s", name)),
+				mcp.WithMIMEType("application/sql"),
+			),
+			func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+				ctx, cancel := withCallTimeout(ctx, cfg.Query.Timeout)
+				defer cancel()
+
+				zap.S().Debugw("reading resource", "uri", request.Params.URI)
+				conn, release, err := database.InspectConn(ctx)
+				if err != nil {
+					zap.S().Errorw("failed to get database connection", "database", database.Name, "error", err)
+					return nil, err
+				}
+				defer release()
+
+				ddl, err := schemaDDL(ctx, conn)
+				if err != nil {
+					zap.S().Errorw("failed to read database schema", "database", database.Name, "error", err)
+					return nil, err
+				}
+
+				return []mcp.ResourceContents{
+					mcp.TextResourceContents{URI: request.Params.URI, MIMEType: "application/sql", Text: ddl},
+				}, nil
+			},
+		)
+	}
+
+	// Columns and CREATE statement of one table
+	mcpServer.AddResourceTemplate(
+		mcp.NewResourceTemplate(TableSchemaTemplateURI, "Table schema",
+			mcp.WithTemplateDescription("Columns, indexes, foreign keys, triggers and CREATE statement of a table of a database, as returned by describe_table"),
+			mcp.WithTemplateMIMEType("application/json"),
+		),
+		func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+			ctx, cancel := withCallTimeout(ctx, cfg.Query.Timeout)
+			defer cancel()
+
+			database, err := databases.Get(resourceArgument(request, "database"))
+			if err != nil {
+				return nil, err
+			}
+			tableName := resourceArgument(request, "name")
+			if tableName == "" {
+				return nil, fmt.Errorf("table name is required")
+			}
+			zap.S().Debugw("reading resource", "uri", request.Params.URI, "database", database.Name, "table_name", tableName)
+
+			conn, release, err := database.InspectConn(ctx)
+			if err != nil {
+				zap.S().Errorw("failed to get database connection", "database", database.Name, "error", err)
+				return nil, err
+			}
+			defer release()
+
+			table, err := resolveTable(ctx, conn, tableName)
+			if err != nil {
+				zap.S().Warnw("failed to resolve table", "table_name", tableName, "error", err)
+				return nil, err
+			}
+			desc, err := describeTable(ctx, conn, table)
+			if err != nil {
+				zap.S().Errorw("failed to get table information", "table_name", tableName, "error", err)
+				return nil, err
+			}
+
+			return jsonResourceContents(request.Params.URI, desc)
+		},
+	)
+
+	// First rows of one table
+	mcpServer.AddResourceTemplate(
+		mcp.NewResourceTemplate(TableSampleTemplateURI, "Table sample",
+			mcp.WithTemplateDescription(fmt.Sprintf("First %d rows of a table of a database", resourceSampleRows)),
+			mcp.WithTemplateMIMEType("application/json"),
+		),
+		func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+			ctx, cancel := withCallTimeout(ctx, cfg.Query.Timeout)
+			defer cancel()
+
+			database, err := databases.Get(resourceArgument(request, "database"))
+			if err != nil {
+				return nil, err
+			}
+			tableName := resourceArgument(request, "name")
+			if tableName == "" {
+				return nil, fmt.Errorf("table name is required")
+			}
+			zap.S().Debugw("reading resource", "uri", request.Params.URI, "database", database.Name, "table_name", tableName)
+
+			conn, release, err := database.InspectConn(ctx)
+			if err != nil {
+				zap.S().Errorw("failed to get database connection", "database", database.Name, "error", err)
+				return nil, err
+			}
+			defer release()
+
+			table, err := resolveTable(ctx, conn, tableName)
+			if err != nil {
+				zap.S().Warnw("failed to resolve table", "table_name", tableName, "error", err)
+				return nil, err
+			}
+			page, err := sampleTable(ctx, conn, encoder, table)
+			if err != nil {
+				zap.S().Errorw("failed to sample table", "table_name", tableName, "error", err)
+				return nil, err
+			}
+
+			return jsonResourceContents(request.Params.URI, jsonObjectsResult{Rows: page.objects(), pageMeta: page.meta()})
+		},
+	)
+
+	return nil
+}
+
+// resourceArgument - Value matched by a variable of a resource template, or "" when it is missing
+func resourceArgument(request mcp.ReadResourceRequest, name string) string {
+	switch v := request.Params.Arguments[name].(type) {
+	case string:
+		return v
+	case []string:
+		// Template matches carry the expanded values of each variable
+		if len(v) == 1 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+// jsonResourceContents - Marshal a value into JSON resource contents
+func jsonResourceContents(uri string, v interface{}) ([]mcp.ResourceContents, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{URI: uri, MIMEType: "application/json", Text: string(data)},
+	}, nil
+}
+
+// schemaDDL - CREATE statements of the database in creation order
+func schemaDDL(ctx context.Context, db queryer) (string, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT sql FROM sqlite_master WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%' ORDER BY rowid")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var sb strings.Builder
+	for rows.Next() {
+		var statement string
+		if err := rows.Scan(&statement); err != nil {
+			return "", err
+		}
+		sb.WriteString(statement)
+		sb.WriteString(";\n\n")
+	}
+	return sb.String(), rows.Err()
+}
+
+// sampleTable - Read the first rows of a table as a query page
+func sampleTable(ctx context.Context, db queryer, encoder *valueEncoder, table *tableRef) (*queryPage, error) {
+	rows, err := db.QueryContext(ctx,
+		fmt.Sprintf("SELECT * FROM %s LIMIT %d", table.quoted(), resourceSampleRows+1))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	declaredTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	classes := newStorageClassTracker(len(columns))
+
+	page := &queryPage{Columns: columns}
+	for rows.Next() {
+		// The extra row only tells whether the table has more rows
+		if len(page.Rows) == resourceSampleRows {
+			page.Truncated = true
+			break
+		}
+
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range columns {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, err
+		}
+
+		classes.observe(values)
+		for i, val := range values {
+			values[i] = encoder.encode(val)
+		}
+		page.Rows = append(page.Rows, values)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page.ColumnTypes = make([]columnType, len(columns))
+	for i, col := range columns {
+		page.ColumnTypes[i] = columnType{
+			Name:         col,
+			DeclaredType: declaredTypes[i].DatabaseTypeName(),
+			StorageClass: classes.class(i),
+		}
+	}
+	return page, nil
+}
+
+// resourceCompleter - Completes the {database} and {name} variables of the table resource templates
+type resourceCompleter struct {
+	databases *Databases
+}
+
+// NewResourceCompletionProvider - Create a completion provider that suggests database and table names
+func NewResourceCompletionProvider(databases *Databases) server.ResourceCompletionProvider {
+	return &resourceCompleter{databases: databases}
+}
+
+// CompleteResourceArgument - Suggest database or table names starting with the typed value. Table names
+// come from the database already chosen for {database}, or from the default database.
+func (c *resourceCompleter) CompleteResourceArgument(ctx context.Context, uri string, argument mcp.CompleteArgument, completeContext mcp.CompleteContext) (*mcp.Completion, error) {
+	if uri != TableSchemaTemplateURI && uri != TableSampleTemplateURI {
+		return &mcp.Completion{Values: []string{}}, nil
+	}
+
+	var candidates []string
+	switch argument.Name {
+	case "database":
+		candidates = c.databases.Names()
+	case "name":
+		tables, err := c.tableNames(ctx, completeContext.Arguments["database"])
+		if err != nil {
+			zap.S().Errorw("failed to complete table name", "error", err)
+			return nil, err
+		}
+		candidates = tables
+	default:
+		return &mcp.Completion{Values: []string{}}, nil
+	}
+
+	values := []string{}
+	prefix := strings.ToLower(argument.Value)
+	for _, candidate := range candidates {
+		if strings.HasPrefix(strings.ToLower(candidate), prefix) {
+			values = append(values, candidate)
+		}
+	}
+	sort.Strings(values)
+
+	completion := &mcp.Completion{Values: values, Total: len(values)}
+	if len(values) > maxCompletionValues {
+		completion.Values = values[:maxCompletionValues]
+		completion.HasMore = true
+	}
+	return completion, nil
+}
+
+// tableNames - Names of the tables of a database, read on the connection the session uses
+func (c *resourceCompleter) tableNames(ctx context.Context, name string) ([]string, error) {
+	database, err := c.databases.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	conn, release, err := database.InspectConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return listTableNames(ctx, conn)
+}
@@ -0,0 +1,335 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// queryer - Runs queries on a *sql.DB, *sql.Conn or *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// listTablesQuery - Lists the user tables of the main database
+const listTablesQuery = "SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%'"
+
+// listTableNames - Names of the user tables in the database
+func listTableNames(ctx context.Context, db queryer) ([]string, error) {
+	rows, err := db.QueryContext(ctx, listTablesQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var tableName string
+		if err := rows.Scan(&tableName); err != nil {
+			return nil, err
+		}
+		tables = append(tables, tableName)
+	}
+	return tables, rows.Err()
+}
+
+// tableDescription - Structure of a table as reported by describe_table
+type tableDescription struct {
+	Schema string `json:"schema"`
+	Name   string `json:"name"`
+	// Type is table, view, virtual or shadow
+	Type         string           `json:"type"`
+	WithoutRowID bool             `json:"without_rowid"`
+	Strict       bool             `json:"strict"`
+	SQL          string           `json:"sql"`
+	Columns      []columnInfo     `json:"columns"`
+	Indexes      []indexInfo      `json:"indexes"`
+	ForeignKeys  []foreignKeyInfo `json:"foreign_keys"`
+	Triggers     []triggerInfo    `json:"triggers"`
+}
+
+// columnInfo - One column from PRAGMA table_xinfo
+type columnInfo struct {
+	CID        int         `json:"cid"`
+	Name       string      `json:"name"`
+	Type       string      `json:"type"`
+	NotNull    bool        `json:"not_null"`
+	Default    interface{} `json:"default"`
+	PrimaryKey bool        `json:"primary_key"`
+	// PrimaryKeyOrdinal is the 1-based position of the column in the primary key, 0 if not part of it
+	PrimaryKeyOrdinal int  `json:"primary_key_ordinal"`
+	Hidden            bool `json:"hidden"`
+	// Generated is virtual or stored for generated columns
+	Generated string `json:"generated,omitempty"`
+}
+
+// indexInfo - One index from PRAGMA index_list and index_xinfo
+type indexInfo struct {
+	Name   string `json:"name"`
+	Unique bool   `json:"unique"`
+	// Origin is create for CREATE INDEX, unique for UNIQUE constraints and primary_key for PRIMARY KEY constraints
+	Origin  string        `json:"origin"`
+	Partial bool          `json:"partial"`
+	Where   string        `json:"where,omitempty"`
+	Columns []indexColumn `json:"columns"`
+	// SQL is empty for indexes created by constraints
+	SQL string `json:"sql,omitempty"`
+}
+
+// indexColumn - One key column of an index
+type indexColumn struct {
+	// Name is empty for expressions and rowid
+	Name       string `json:"name,omitempty"`
+	Expression bool   `json:"expression,omitempty"`
+	Rowid      bool   `json:"rowid,omitempty"`
+	Desc       bool   `json:"desc"`
+	Collation  string `json:"collation"`
+}
+
+// foreignKeyInfo - One foreign key constraint from PRAGMA foreign_key_list
+type foreignKeyInfo struct {
+	ID    int      `json:"id"`
+	Table string   `json:"table"`
+	From  []string `json:"from"`
+	// To is empty when the constraint references the parent's primary key
+	To       []string `json:"to"`
+	OnUpdate string   `json:"on_update"`
+	OnDelete string   `json:"on_delete"`
+	Match    string   `json:"match"`
+}
+
+// triggerInfo - One trigger attached to the table
+type triggerInfo struct {
+	Name string `json:"name"`
+	SQL  string `json:"sql"`
+}
+
+// indexOrigins - Readable names of the origin column of PRAGMA index_list
+var indexOrigins = map[string]string{
+	"c":  "create",
+	"u":  "unique",
+	"pk": "primary_key",
+}
+
+// describeTable - Collect columns, indexes, foreign keys and triggers of a resolved table.
+// Names are only passed as bound arguments of the pragma table-valued functions.
+func describeTable(ctx context.Context, db queryer, table *tableRef) (*tableDescription, error) {
+	desc := &tableDescription{
+		Schema:      table.Schema,
+		Name:        table.Name,
+		SQL:         table.SQL,
+		Columns:     []columnInfo{},
+		Indexes:     []indexInfo{},
+		ForeignKeys: []foreignKeyInfo{},
+		Triggers:    []triggerInfo{},
+	}
+
+	var withoutRowID, strict int
+	err := db.QueryRowContext(ctx,
+		"SELECT type, wr, strict FROM pragma_table_list WHERE schema = ? AND name = ?",
+		table.Schema, table.Name).Scan(&desc.Type, &withoutRowID, &strict)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table flags: %w", err)
+	}
+	desc.WithoutRowID = withoutRowID == 1
+	desc.Strict = strict == 1
+
+	if desc.Columns, err = tableColumns(ctx, db, table); err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+	if desc.Indexes, err = tableIndexes(ctx, db, table); err != nil {
+		return nil, fmt.Errorf("failed to read indexes: %w", err)
+	}
+	if desc.ForeignKeys, err = tableForeignKeys(ctx, db, table); err != nil {
+		return nil, fmt.Errorf("failed to read foreign keys: %w", err)
+	}
+	if desc.Triggers, err = tableTriggers(ctx, db, table); err != nil {
+		return nil, fmt.Errorf("failed to read triggers: %w", err)
+	}
+
+	return desc, nil
+}
+
+// tableColumns - Columns of a table from PRAGMA table_xinfo, including hidden and generated columns
+func tableColumns(ctx context.Context, db queryer, table *tableRef) ([]columnInfo, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT cid, name, type, \"notnull\", dflt_value, pk, hidden FROM pragma_table_xinfo(?, ?)", table.Name, table.Schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := []columnInfo{}
+	for rows.Next() {
+		var column columnInfo
+		var notNull, hidden int
+		if err := rows.Scan(&column.CID, &column.Name, &column.Type, &notNull, &column.Default, &column.PrimaryKeyOrdinal, &hidden); err != nil {
+			return nil, err
+		}
+		column.NotNull = notNull == 1
+		column.PrimaryKey = column.PrimaryKeyOrdinal > 0
+
+		// hidden is 1 for hidden virtual table columns, 2 and 3 for generated columns
+		switch hidden {
+		case 1:
+			column.Hidden = true
+		case 2:
+			column.Generated = "virtual"
+		case 3:
+			column.Generated = "stored"
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// tableIndexes - Indexes of a table with their key columns
+func tableIndexes(ctx context.Context, db queryer, table *tableRef) ([]indexInfo, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT il.name, il.\"unique\", il.origin, il.partial, m.sql "+
+			fmt.Sprintf("FROM pragma_index_list(?, ?) AS il LEFT JOIN %s.sqlite_master AS m ON m.type = 'index' AND m.name = il.name ", quoteIdentifier(table.Schema))+
+			"ORDER BY il.seq", table.Name, table.Schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	indexes := []indexInfo{}
+	for rows.Next() {
+		var index indexInfo
+		var unique, partial int
+		var definition sql.NullString
+		if err := rows.Scan(&index.Name, &unique, &index.Origin, &partial, &definition); err != nil {
+			return nil, err
+		}
+		index.Unique = unique == 1
+		index.Partial = partial == 1
+		if origin, ok := indexOrigins[index.Origin]; ok {
+			index.Origin = origin
+		}
+		index.SQL = definition.String
+		if index.Partial {
+			index.Where = partialIndexWhere(index.SQL)
+		}
+		indexes = append(indexes, index)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range indexes {
+		if indexes[i].Columns, err = indexColumns(ctx, db, table.Schema, indexes[i].Name); err != nil {
+			return nil, err
+		}
+	}
+	return indexes, nil
+}
+
+// indexColumns - Key columns of an index from PRAGMA index_xinfo
+func indexColumns(ctx context.Context, db queryer, schema, indexName string) ([]indexColumn, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT cid, name, \"desc\", coll FROM pragma_index_xinfo(?, ?) WHERE key = 1 ORDER BY seqno", indexName, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := []indexColumn{}
+	for rows.Next() {
+		var column indexColumn
+		var cid, desc int
+		var name, collation sql.NullString
+		if err := rows.Scan(&cid, &name, &desc, &collation); err != nil {
+			return nil, err
+		}
+		// cid is -1 for the rowid and -2 for an expression
+		switch cid {
+		case -1:
+			column.Rowid = true
+		case -2:
+			column.Expression = true
+		default:
+			column.Name = name.String
+		}
+		column.Desc = desc == 1
+		column.Collation = collation.String
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// partialIndexWhere - WHERE clause of a CREATE INDEX statement
+func partialIndexWhere(definition string) string {
+	depth := 0
+	for _, token := range tokenizeSQL(definition) {
+		switch {
+		case token.Kind == tokenPunct && token.Text == "(":
+			depth++
+		case token.Kind == tokenPunct && token.Text == ")":
+			depth--
+		case depth == 0 && token.isKeyword("WHERE"):
+			return strings.TrimSpace(definition[token.Offset+len(token.Text):])
+		}
+	}
+	return ""
+}
+
+// tableForeignKeys - Foreign key constraints of a table, one entry per constraint
+func tableForeignKeys(ctx context.Context, db queryer, table *tableRef) ([]foreignKeyInfo, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT id, \"table\", \"from\", \"to\", on_update, on_delete, \"match\" FROM pragma_foreign_key_list(?, ?) ORDER BY id, seq", table.Name, table.Schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	foreignKeys := []foreignKeyInfo{}
+	for rows.Next() {
+		var fk foreignKeyInfo
+		var from string
+		var to sql.NullString
+		if err := rows.Scan(&fk.ID, &fk.Table, &from, &to, &fk.OnUpdate, &fk.OnDelete, &fk.Match); err != nil {
+			return nil, err
+		}
+
+		// Rows of a composite key share the constraint id
+		if n := len(foreignKeys); n > 0 && foreignKeys[n-1].ID == fk.ID {
+			foreignKeys[n-1].From = append(foreignKeys[n-1].From, from)
+			if to.Valid {
+				foreignKeys[n-1].To = append(foreignKeys[n-1].To, to.String)
+			}
+			continue
+		}
+		fk.From = []string{from}
+		fk.To = []string{}
+		if to.Valid {
+			fk.To = append(fk.To, to.String)
+		}
+		foreignKeys = append(foreignKeys, fk)
+	}
+	return foreignKeys, rows.Err()
+}
+
+// tableTriggers - Triggers attached to a table
+func tableTriggers(ctx context.Context, db queryer, table *tableRef) ([]triggerInfo, error) {
+	rows, err := db.QueryContext(ctx,
+		fmt.Sprintf("SELECT name, sql FROM %s.sqlite_master WHERE type = 'trigger' AND tbl_name = ? ORDER BY name", quoteIdentifier(table.Schema)),
+		table.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	triggers := []triggerInfo{}
+	for rows.Next() {
+		var trigger triggerInfo
+		if err := rows.Scan(&trigger.Name, &trigger.SQL); err != nil {
+			return nil, err
+		}
+		triggers = append(triggers, trigger)
+	}
+	return triggers, rows.Err()
+}
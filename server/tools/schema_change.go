@@ -0,0 +1,418 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/cnosuke/mcp-sqlite/config"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// SchemaChangeArgs - Arguments for schema_change tool (kept for testing compatibility)
+type SchemaChangeArgs struct {
+	Query             string `json:"query" jsonschema:"description=CREATE, ALTER or DROP statement for a table, index, view or trigger"`
+	ConfirmationToken string `json:"confirmation_token,omitempty" jsonschema:"description=Token returned when a destructive statement was held back; repeat the same call with it to execute the statement"`
+	TimeoutMS         int    `json:"timeout_ms,omitempty" jsonschema:"description=Timeout in milliseconds"`
+	Database          string `json:"database,omitempty" jsonschema:"description=Name of the database to use"`
+	Transaction       string `json:"transaction,omitempty" jsonschema:"description=Transaction handle returned by begin_transaction"`
+}
+
+// ddlObjectTypes - Object types schema_change creates, alters or drops, with their sqlite_master type
+var ddlObjectTypes = map[string]string{
+	"TABLE":         "table",
+	"VIRTUAL TABLE": "table",
+	"INDEX":         "index",
+	"VIEW":          "view",
+	"TRIGGER":       "trigger",
+}
+
+// ddlTarget - The object a DDL statement creates, alters or drops
+type ddlTarget struct {
+	// Operation is CREATE, ALTER or DROP
+	Operation string
+	// Object is the object type as written, e.g. TABLE, VIRTUAL TABLE, INDEX
+	Object string
+	// Schema is the schema the name is qualified with, or "" for an unqualified name
+	Schema string
+	Name   string
+	// Temp is set for CREATE TEMP/TEMPORARY statements
+	Temp bool
+	// NewName is the new name of an ALTER TABLE ... RENAME TO statement
+	NewName string
+}
+
+// parseDDLTarget - Find the object a CREATE, ALTER or DROP statement is about, skipping
+// TEMP, UNIQUE and IF [NOT] EXISTS. Each part of the name may be quoted.
+func parseDDLTarget(stmt string) (*ddlTarget, error) {
+	tokens := tokenizeSQL(stmt)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty statement")
+	}
+	class := leadingKeywords(tokens)
+	if _, ok := ddlObjectTypes[class.Object]; !ok || (class.Kind != "CREATE" && class.Kind != "ALTER" && class.Kind != "DROP") {
+		return nil, fmt.Errorf("%s is not supported; expected CREATE, ALTER or DROP of a TABLE, INDEX, VIEW or TRIGGER", statementKindName(class))
+	}
+	if class.Kind == "ALTER" && class.Object != "TABLE" {
+		return nil, fmt.Errorf("ALTER %s is not supported by SQLite", class.Object)
+	}
+	if class.Kind != "CREATE" && class.Object == "VIRTUAL TABLE" {
+		return nil, fmt.Errorf("%s is not a valid statement", statementKindName(class))
+	}
+	target := &ddlTarget{Operation: class.Kind, Object: class.Object, Temp: class.Temp}
+
+	// Skip the verb and the modifiers up to and including the object type
+	rest := tokens[1:]
+	for len(rest) > 0 && (rest[0].isKeyword("TEMP") || rest[0].isKeyword("TEMPORARY") || rest[0].isKeyword("UNIQUE") || rest[0].isKeyword("VIRTUAL")) {
+		rest = rest[1:]
+	}
+	rest = rest[1:]
+	if len(rest) > 0 && rest[0].isKeyword("IF") {
+		rest = rest[1:]
+		if len(rest) > 0 && rest[0].isKeyword("NOT") {
+			rest = rest[1:]
+		}
+		if len(rest) == 0 || !rest[0].isKeyword("EXISTS") {
+			return nil, fmt.Errorf("expected EXISTS after IF")
+		}
+		rest = rest[1:]
+	}
+
+	name, rest, err := ddlName(rest)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 1 && rest[0].Text == "." {
+		target.Schema = name
+		if name, rest, err = ddlName(rest[1:]); err != nil {
+			return nil, err
+		}
+	}
+	target.Name = name
+
+	// ALTER TABLE name RENAME TO new_name, as opposed to RENAME [COLUMN] old TO new
+	if target.Operation == "ALTER" && len(rest) > 2 && rest[0].isKeyword("RENAME") && rest[1].isKeyword("TO") {
+		if target.NewName, _, err = ddlName(rest[2:]); err != nil {
+			return nil, err
+		}
+	}
+	return target, nil
+}
+
+// ddlName - Read one, possibly quoted, name from the tokens
+func ddlName(tokens []sqlToken) (string, []sqlToken, error) {
+	if len(tokens) == 0 {
+		return "", nil, fmt.Errorf("missing object name")
+	}
+	switch token := tokens[0]; token.Kind {
+	case tokenWord:
+		return token.Text, tokens[1:], nil
+	case tokenQuotedIdent, tokenString:
+		// SQLite accepts a string literal where it expects a name
+		if name, ok := unquoteIdentifier(token.Text); ok {
+			return name, tokens[1:], nil
+		}
+		if len(token.Text) >= 2 && token.Text[0] == '\'' {
+			return strings.ReplaceAll(token.Text[1:len(token.Text)-1], "''", "'"), tokens[1:], nil
+		}
+	}
+	return "", nil, fmt.Errorf("invalid object name %s", tokens[0].Text)
+}
+
+// schemaObject - Definition of a schema object as stored in sqlite_master
+type schemaObject struct {
+	Schema string `json:"schema"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	// Table is the table an index or trigger belongs to; for tables and views it is the name itself
+	Table string `json:"table"`
+	// SQL is the CREATE statement; empty for indexes SQLite creates itself
+	SQL string `json:"sql"`
+}
+
+// findSchemaObject - Look up a schema object by name. Unqualified names are searched in the given
+// order of schemas; a qualified name only in its schema.
+func findSchemaObject(ctx context.Context, db queryer, schemas []string, objectType, name string) (*schemaObject, error) {
+	for _, schema := range schemas {
+		object := schemaObject{Schema: schema}
+		query := fmt.Sprintf("SELECT type, name, tbl_name, IFNULL(sql, '') FROM %s.sqlite_master WHERE type = ? AND name = ? COLLATE NOCASE", quoteIdentifier(schema))
+		err := db.QueryRowContext(ctx, query, objectType, name).Scan(&object.Type, &object.Name, &object.Table, &object.SQL)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &object, nil
+	}
+	return nil, nil
+}
+
+// dependentObjects - The indexes and triggers that belong to a table or view and are dropped with it
+func dependentObjects(ctx context.Context, db queryer, object *schemaObject) ([]schemaObject, error) {
+	query := fmt.Sprintf("SELECT type, name, tbl_name, IFNULL(sql, '') FROM %s.sqlite_master WHERE tbl_name = ? COLLATE NOCASE AND type IN ('index', 'trigger') ORDER BY type, name", quoteIdentifier(object.Schema))
+	rows, err := db.QueryContext(ctx, query, object.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dependents := []schemaObject{}
+	for rows.Next() {
+		dependent := schemaObject{Schema: object.Schema}
+		if err := rows.Scan(&dependent.Type, &dependent.Name, &dependent.Table, &dependent.SQL); err != nil {
+			return nil, err
+		}
+		dependents = append(dependents, dependent)
+	}
+	return dependents, rows.Err()
+}
+
+// lookupSchemas - Schemas to search for the object of a DDL statement, in the order SQLite
+// resolves its name: CREATE puts an unqualified object into main, or temp for TEMP objects;
+// ALTER and DROP find it in temp, main, then the attached databases.
+func (t *ddlTarget) lookupSchemas(ctx context.Context, db queryer) ([]string, error) {
+	if t.Schema != "" {
+		return []string{t.Schema}, nil
+	}
+	if t.Operation == "CREATE" && t.Temp {
+		return []string{"temp"}, nil
+	}
+	if t.Operation == "CREATE" {
+		// Indexes and triggers on TEMP tables go to temp without the keyword
+		return []string{"main", "temp"}, nil
+	}
+
+	names, err := databaseNames(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	schemas := []string{"temp"}
+	for _, name := range names {
+		if name != "temp" {
+			schemas = append(schemas, name)
+		}
+	}
+	return schemas, nil
+}
+
+// schemaChangePreview - What a schema change held back for confirmation would affect
+type schemaChangePreview struct {
+	Object *schemaObject `json:"object"`
+	// Rows is the number of rows in the table
+	Rows *int64 `json:"rows,omitempty"`
+	// Dependents are the indexes and triggers that a DROP removes with the table or view
+	Dependents []schemaObject `json:"dependents,omitempty"`
+}
+
+// schemaChangeResult - Response body of schema_change
+type schemaChangeResult struct {
+	Operation string `json:"operation"`
+	Object    string `json:"object_type"`
+	Name      string `json:"name"`
+	// Changed is false when IF [NOT] EXISTS made the statement a no-op
+	Changed bool `json:"changed"`
+	// Definition is the object after the statement; absent after DROP
+	Definition *schemaObject `json:"definition"`
+	// Previous is the object before the statement; absent for a new object
+	Previous *schemaObject `json:"previous"`
+}
+
+// previewSchemaChange - Describe the object a statement is about to change
+func previewSchemaChange(ctx context.Context, db queryer, target *ddlTarget, object *schemaObject) (schemaChangePreview, error) {
+	preview := schemaChangePreview{Object: object}
+	if object == nil {
+		return preview, nil
+	}
+	if object.Type == "table" && !strings.HasPrefix(strings.ToUpper(object.SQL), "CREATE VIRTUAL") {
+		var rows int64
+		table := tableRef{Schema: object.Schema, Name: object.Name}
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table.quoted()).Scan(&rows); err != nil {
+			return preview, err
+		}
+		preview.Rows = &rows
+	}
+	if target.Operation == "DROP" && (object.Type == "table" || object.Type == "view") {
+		dependents, err := dependentObjects(ctx, db, object)
+		if err != nil {
+			return preview, err
+		}
+		preview.Dependents = dependents
+	}
+	return preview, nil
+}
+
+// RegisterSchemaChangeTool - Register the schema_change tool
+func RegisterSchemaChangeTool(mcpServer *server.MCPServer, databases *Databases, cfg *config.Config) error {
+	zap.S().Debug("registering schema_change tool")
+
+	if cfg.Confirmation.Enabled && cfg.Confirmation.TokenTTL <= 0 {
+		return fmt.Errorf("confirmation.token_ttl must be positive, got %s", cfg.Confirmation.TokenTTL)
+	}
+
+	// Define the tool
+	tool := mcp.NewTool("schema_change",
+		mcp.WithDescription("Create, alter or drop tables, indexes, views and triggers with a single CREATE, ALTER TABLE or DROP statement. "+
+			"Returns the definition of the object from sqlite_master before and after the change. DROP statements and changes to protected tables need confirmation"),
+		mcp.WithString("query",
+			mcp.Description("One CREATE, ALTER TABLE or DROP statement. CREATE TEMP objects last for the client session"),
+			mcp.Required(),
+		),
+		mcp.WithString("confirmation_token",
+			mcp.Description("Token returned when a destructive statement was held back for confirmation. Repeat the same call with it to execute the statement"),
+		),
+		mcp.WithNumber("timeout_ms",
+			mcp.Description(timeoutDescription),
+			mcp.Min(1),
+		),
+		databaseArgument(databases),
+		transactionArgument(),
+	)
+
+	// Add the tool handler
+	mcpServer.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		// Extract query parameter
+		query, ok := request.GetArguments()["query"].(string)
+		if !ok || query == "" {
+			return mcp.NewToolResultError("query parameter is required"), nil
+		}
+
+		target, err := parseDDLTarget(query)
+		if err != nil {
+			zap.S().Warnw("invalid query for schema_change", "query", query, "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		handle := request.GetString("transaction", "")
+		database, err := callDatabase(databases, request.GetString("database", ""), handle, true)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		// A confirmation token only runs the exact call it was issued for
+		token := request.GetString("confirmation_token", "")
+		key, err := confirmationKey("schema_change", database.Name, handle, query, nil)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if token != "" {
+			if err := databases.confirmations.redeem(token, key, sessionIDFromContext(ctx)); err != nil {
+				zap.S().Warnw("rejected confirmation token", "error", err)
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			zap.S().Infow("schema_change confirmed", "database", database.Name)
+		}
+
+		timeout, err := callTimeout(cfg, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx, cancel := withCallTimeout(ctx, timeout)
+		defer cancel()
+
+		zap.S().Debugw("executing schema_change", "query", query, "transaction", handle, "timeout", timeout)
+
+		conn, release, err := callConn(ctx, databases, database, handle)
+		if err != nil {
+			zap.S().Errorw("failed to get database connection", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		defer release()
+
+		// Compiling the statement also checks it against the database's authorizer
+		class, err := classifyStatement(ctx, conn, query)
+		if err != nil {
+			zap.S().Warnw("failed to classify query",
+				"query", query,
+				"error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		objectType := ddlObjectTypes[target.Object]
+		schemas, err := target.lookupSchemas(ctx, conn)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		previous, err := findSchemaObject(ctx, conn, schemas, objectType, target.Name)
+		if err != nil {
+			zap.S().Errorw("failed to look up schema object", "name", target.Name, "error", err)
+			return errorResult(ctx, timeout, err), nil
+		}
+
+		// Destructive statements are held back until the call is repeated with a token
+		if cfg.Confirmation.Enabled && token == "" {
+			risks := &riskCollector{rules: cfg.Confirmation, authorizer: database.Authorizer}
+			stmt := sqlStatement{Text: query, Line: 1}
+			if err := risks.check(conn, 0, stmt, class); err != nil {
+				zap.S().Errorw("failed to check statement for confirmation", "error", err)
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			// DROP ... IF EXISTS of a missing object changes nothing
+			if len(risks.risks) > 0 && (previous != nil || target.Operation != "DROP") {
+				preview, err := previewSchemaChange(ctx, conn, target, previous)
+				if err != nil {
+					zap.S().Errorw("failed to preview schema change", "error", err)
+					return errorResult(ctx, timeout, err), nil
+				}
+				zap.S().Infow("schema_change held back for confirmation",
+					"database", database.Name,
+					"risks", len(risks.risks))
+				return holdForConfirmation(ctx, databases, cfg.Confirmation, key, risks.risks, preview), nil
+			}
+		}
+
+		// Execute query
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			zap.S().Errorw("failed to change schema",
+				"query", query,
+				"error", err)
+			return errorResult(ctx, timeout, err), nil
+		}
+
+		result := schemaChangeResult{
+			Operation: target.Operation,
+			Object:    target.Object,
+			Name:      target.Name,
+			Previous:  previous,
+		}
+		if target.Operation != "DROP" {
+			name := target.Name
+			if target.NewName != "" {
+				name = target.NewName
+			}
+			if previous != nil {
+				// The object stays in the schema it was found in
+				schemas = []string{previous.Schema}
+			}
+			if result.Definition, err = findSchemaObject(ctx, conn, schemas, objectType, name); err != nil {
+				zap.S().Errorw("failed to look up schema object", "name", name, "error", err)
+				return errorResult(ctx, timeout, err), nil
+			}
+		}
+		switch {
+		case result.Definition == nil || result.Previous == nil:
+			result.Changed = result.Definition != result.Previous
+		default:
+			result.Changed = *result.Definition != *result.Previous
+		}
+
+		zap.S().Infow("schema changed",
+			"database", database.Name,
+			"operation", target.Operation,
+			"object_type", target.Object,
+			"name", target.Name,
+			"changed", result.Changed)
+
+		toolResult, err := jsonToolResult(result)
+		if err != nil {
+			zap.S().Errorw("failed to convert result to JSON", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return toolResult, nil
+	})
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// sessionConn - A connection pinned to one client session
+type sessionConn struct {
+	conn *sql.Conn
+	// busy holds a token while a tool call of the session uses the connection
+	busy     chan struct{}
+	timer    *time.Timer
+	lastUsed time.Time
+	// closed is set once the connection was discarded; guarded by busy
+	closed bool
+	// tx is the transaction handle open on the connection, which shares busy with it; guarded by busy
+	tx *transaction
+}
+
+// Conn - Take the connection a tool call should use, with the current attachments applied.
+// Calls made within a client session get the connection pinned to that session, so TEMP
+// tables and connection state carry over between calls; other calls get a pooled connection.
+// While a transaction handle is open on the session's connection, only calls passing the handle
+// and InspectConn may use it. The returned function must be called when the tool is done with the connection.
+func (d *Database) Conn(ctx context.Context) (*sql.Conn, func(), error) {
+	return d.conn(ctx, false)
+}
+
+// InspectConn - Like Conn, for calls that only read the schema. While a transaction handle is open
+// on the session's connection, they run inside it and see its uncommitted changes.
+func (d *Database) InspectConn(ctx context.Context) (*sql.Conn, func(), error) {
+	return d.conn(ctx, true)
+}
+
+// conn - Conn, or InspectConn when inspect is set
+func (d *Database) conn(ctx context.Context, inspect bool) (*sql.Conn, func(), error) {
+	sessionID := sessionIDFromContext(ctx)
+	if sessionID == "" || d.IdleTimeout <= 0 {
+		conn, err := d.DB.Conn(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := d.syncAttachments(ctx, conn); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		generation := d.generation.Load()
+		release := func() {
+			if d.generation.Load() != generation {
+				// The database was restored while the connection was in use
+				discardConn(conn)
+				return
+			}
+			conn.Close()
+		}
+		return conn, release, nil
+	}
+
+	pinned, err := d.acquireSessionConn(ctx, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pinned.tx != nil {
+		if inspect {
+			// Attachments cannot change inside the transaction, and its idle timer stays stopped
+			return pinned.conn, func() { <-pinned.busy }, nil
+		}
+		// The call would otherwise run inside the transaction
+		id := pinned.tx.ID
+		<-pinned.busy
+		return nil, nil, &openTransactionError{id: id, database: d.Name}
+	}
+	release := func() { d.releaseSessionConn(sessionID, pinned) }
+	if err := d.syncAttachments(ctx, pinned.conn); err != nil {
+		release()
+		return nil, nil, err
+	}
+	return pinned.conn, release, nil
+}
+
+// openTransactionError - A call without the handle found a transaction open on the session's connection
+type openTransactionError struct {
+	id       string
+	database string
+	// argument is set when the tool takes the handle as its transaction argument
+	argument bool
+}
+
+func (e *openTransactionError) Error() string {
+	if e.argument {
+		return fmt.Sprintf("transaction %s is open on this session's connection to %s; pass it as the transaction argument or end it first", e.id, e.database)
+	}
+	return fmt.Sprintf("transaction %s is open on this session's connection to %s; commit or roll it back first", e.id, e.database)
+}
+
+// acquireSessionConn - Get the connection pinned to the session, opening it on first use,
+// and wait until no other call of the session is using it
+func (d *Database) acquireSessionConn(ctx context.Context, sessionID string) (*sessionConn, error) {
+	for {
+		d.sessionsMu.Lock()
+		pinned, ok := d.sessions[sessionID]
+		if !ok {
+			conn, err := d.DB.Conn(ctx)
+			if err != nil {
+				d.sessionsMu.Unlock()
+				return nil, err
+			}
+			if d.sessions == nil {
+				d.sessions = make(map[string]*sessionConn)
+			}
+			pinned = &sessionConn{conn: conn, busy: make(chan struct{}, 1)}
+			d.sessions[sessionID] = pinned
+			zap.S().Debugw("pinned connection to session", "database", d.Name, "session_id", sessionID)
+		}
+		d.sessionsMu.Unlock()
+
+		select {
+		case pinned.busy <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if pinned.closed {
+			// Expired while this call was waiting; open a new one
+			<-pinned.busy
+			continue
+		}
+		if pinned.timer != nil {
+			pinned.timer.Stop()
+		}
+		return pinned, nil
+	}
+}
+
+// releaseSessionConn - Hand the pinned connection back to the session and restart its idle timer
+func (d *Database) releaseSessionConn(sessionID string, pinned *sessionConn) {
+	d.restartIdleTimer(sessionID, pinned)
+	<-pinned.busy
+}
+
+// restartIdleTimer - Start the idle timeout of a pinned connection over. The caller holds busy.
+func (d *Database) restartIdleTimer(sessionID string, pinned *sessionConn) {
+	pinned.lastUsed = time.Now()
+	if pinned.timer == nil {
+		pinned.timer = time.AfterFunc(d.IdleTimeout, func() { d.expireSessionConn(sessionID, pinned) })
+	} else {
+		pinned.timer.Reset(d.IdleTimeout)
+	}
+}
+
+// expireSessionConn - Close a pinned connection that has not been used for the idle timeout
+func (d *Database) expireSessionConn(sessionID string, pinned *sessionConn) {
+	pinned.busy <- struct{}{}
+	defer func() { <-pinned.busy }()
+
+	// A call may have taken the connection just before the timer fired, and an open transaction
+	// is ended by its own auto-rollback timer
+	if pinned.closed || pinned.tx != nil || time.Since(pinned.lastUsed) < d.IdleTimeout {
+		return
+	}
+	zap.S().Infow("closing idle session connection", "database", d.Name, "session_id", sessionID)
+	d.discardSessionConn(sessionID, pinned)
+}
+
+// CloseSession - Close the connection pinned to a session, waiting for a running call to finish
+func (d *Database) CloseSession(sessionID string) {
+	d.sessionsMu.Lock()
+	pinned, ok := d.sessions[sessionID]
+	d.sessionsMu.Unlock()
+	if !ok {
+		return
+	}
+
+	pinned.busy <- struct{}{}
+	defer func() { <-pinned.busy }()
+	if pinned.closed {
+		return
+	}
+	zap.S().Debugw("closing session connection", "database", d.Name, "session_id", sessionID)
+	d.discardSessionConn(sessionID, pinned)
+}
+
+// discardSessionConn - Forget a pinned connection and close it instead of returning it to the pool,
+// so that its TEMP tables and settings do not leak into other sessions. The caller holds busy and has
+// rolled back a transaction open on the connection.
+func (d *Database) discardSessionConn(sessionID string, pinned *sessionConn) {
+	d.sessionsMu.Lock()
+	if d.sessions[sessionID] == pinned {
+		delete(d.sessions, sessionID)
+	}
+	d.sessionsMu.Unlock()
+
+	pinned.closed = true
+	if pinned.timer != nil {
+		pinned.timer.Stop()
+	}
+	discardConn(pinned.conn)
+}
+
+// discardConn - Close a connection for good instead of returning it to the pool
+func discardConn(conn *sql.Conn) {
+	conn.Raw(func(any) error { return driver.ErrBadConn })
+	conn.Close()
+}
+
+// CloseSessions - Close the connections pinned to all sessions
+func (d *Database) CloseSessions() {
+	d.sessionsMu.Lock()
+	sessionIDs := make([]string, 0, len(d.sessions))
+	for sessionID := range d.sessions {
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	d.sessionsMu.Unlock()
+
+	for _, sessionID := range sessionIDs {
+		d.CloseSession(sessionID)
+	}
+}
+
+// CloseSession - Roll back the transactions a session left open and close the connections
+// it has pinned in every database
+func (d *Databases) CloseSession(sessionID string) {
+	d.rollbackTransactions(func(t *transaction) bool { return t.SessionID == sessionID }, "rolled back when the client session ended")
+	for _, database := range d.byName {
+		database.CloseSession(sessionID)
+	}
+}
+
+// sessionIDFromContext - ID of the client session a call belongs to, or "" outside of a session
+func sessionIDFromContext(ctx context.Context) string {
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil {
+		return ""
+	}
+	return session.SessionID()
+}
@@ -0,0 +1,160 @@
+package tools
+
+import (
+	"strings"
+)
+
+// sqlTokenKind - Kind of a lexical SQL token
+type sqlTokenKind int
+
+const (
+	// tokenWord is a keyword or bare identifier
+	tokenWord sqlTokenKind = iota
+	// tokenQuotedIdent is an identifier quoted with "", [] or ``
+	tokenQuotedIdent
+	// tokenString is a string or blob literal
+	tokenString
+	// tokenNumber is a numeric literal
+	tokenNumber
+	// tokenParam is a bind parameter such as ?, ?1, :name, @name or $name
+	tokenParam
+	// tokenSemicolon terminates a statement
+	tokenSemicolon
+	// tokenPunct is any other operator or punctuation
+	tokenPunct
+)
+
+// sqlToken - A lexical SQL token with its byte offset in the source
+type sqlToken struct {
+	Kind   sqlTokenKind
+	Text   string
+	Offset int
+}
+
+// upper - Upper-cased token text, used for keyword comparison
+func (t sqlToken) upper() string {
+	return strings.ToUpper(t.Text)
+}
+
+// isKeyword - Check whether the token is the given bare keyword
+func (t sqlToken) isKeyword(keyword string) bool {
+	return t.Kind == tokenWord && strings.EqualFold(t.Text, keyword)
+}
+
+// tokenizeSQL - Split SQL text into tokens, skipping whitespace and comments.
+// Unterminated literals and comments run to the end of the input.
+func tokenizeSQL(sql string) []sqlToken {
+	var tokens []sqlToken
+	i := 0
+	n := len(sql)
+
+	for i < n {
+		c := sql[i]
+		start := i
+
+		switch {
+		case isSQLSpace(c):
+			i++
+			continue
+		case c == '-' && i+1 < n && sql[i+1] == '-':
+			// Line comment
+			for i < n && sql[i] != '\n' {
+				i++
+			}
+			continue
+		case c == '/' && i+1 < n && sql[i+1] == '*':
+			// Block comment
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = n
+			} else {
+				i += 2 + end + 2
+			}
+			continue
+		case c == '\'':
+			i = scanQuoted(sql, i, '\'')
+			tokens = append(tokens, sqlToken{Kind: tokenString, Text: sql[start:i], Offset: start})
+		case (c == 'x' || c == 'X') && i+1 < n && sql[i+1] == '\'':
+			// Blob literal
+			i = scanQuoted(sql, i+1, '\'')
+			tokens = append(tokens, sqlToken{Kind: tokenString, Text: sql[start:i], Offset: start})
+		case c == '"':
+			i = scanQuoted(sql, i, '"')
+			tokens = append(tokens, sqlToken{Kind: tokenQuotedIdent, Text: sql[start:i], Offset: start})
+		case c == '`':
+			i = scanQuoted(sql, i, '`')
+			tokens = append(tokens, sqlToken{Kind: tokenQuotedIdent, Text: sql[start:i], Offset: start})
+		case c == '[':
+			end := strings.IndexByte(sql[i:], ']')
+			if end < 0 {
+				i = n
+			} else {
+				i += end + 1
+			}
+			tokens = append(tokens, sqlToken{Kind: tokenQuotedIdent, Text: sql[start:i], Offset: start})
+		case c == ';':
+			i++
+			tokens = append(tokens, sqlToken{Kind: tokenSemicolon, Text: ";", Offset: start})
+		case c == '?':
+			i++
+			for i < n && isSQLDigit(sql[i]) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{Kind: tokenParam, Text: sql[start:i], Offset: start})
+		case (c == ':' || c == '@' || c == '$') && i+1 < n && isSQLIdentChar(sql[i+1]):
+			i++
+			for i < n && isSQLIdentChar(sql[i]) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{Kind: tokenParam, Text: sql[start:i], Offset: start})
+		case isSQLDigit(c) || (c == '.' && i+1 < n && isSQLDigit(sql[i+1])):
+			for i < n && (isSQLIdentChar(sql[i]) || sql[i] == '.' ||
+				((sql[i] == '+' || sql[i] == '-') && (sql[i-1] == 'e' || sql[i-1] == 'E'))) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{Kind: tokenNumber, Text: sql[start:i], Offset: start})
+		case isSQLIdentStart(c):
+			for i < n && isSQLIdentChar(sql[i]) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{Kind: tokenWord, Text: sql[start:i], Offset: start})
+		default:
+			i++
+			tokens = append(tokens, sqlToken{Kind: tokenPunct, Text: sql[start:i], Offset: start})
+		}
+	}
+
+	return tokens
+}
+
+// scanQuoted - Return the index just past a quoted section starting at i, where a doubled quote is an escape
+func scanQuoted(sql string, i int, quote byte) int {
+	i++
+	for i < len(sql) {
+		if sql[i] == quote {
+			if i+1 < len(sql) && sql[i+1] == quote {
+				i += 2
+				continue
+			}
+			return i + 1
+		}
+		i++
+	}
+	return i
+}
+
+func isSQLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isSQLDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isSQLIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isSQLIdentChar(c byte) bool {
+	return isSQLIdentStart(c) || isSQLDigit(c) || c == '$'
+}
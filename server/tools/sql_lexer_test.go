@@ -0,0 +1,114 @@
+package tools
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenizeSQL(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []sqlToken
+	}{
+		{
+			name: "words, numbers and punctuation",
+			sql:  "SELECT a, 1.5e-3 FROM t",
+			want: []sqlToken{
+				{Kind: tokenWord, Text: "SELECT", Offset: 0},
+				{Kind: tokenWord, Text: "a", Offset: 7},
+				{Kind: tokenPunct, Text: ",", Offset: 8},
+				{Kind: tokenNumber, Text: "1.5e-3", Offset: 10},
+				{Kind: tokenWord, Text: "FROM", Offset: 17},
+				{Kind: tokenWord, Text: "t", Offset: 22},
+			},
+		},
+		{
+			name: "string with doubled quote and semicolon",
+			sql:  "'it''s; fine';",
+			want: []sqlToken{
+				{Kind: tokenString, Text: "'it''s; fine'", Offset: 0},
+				{Kind: tokenSemicolon, Text: ";", Offset: 13},
+			},
+		},
+		{
+			name: "blob literal",
+			sql:  "x'00ff' X'AB'",
+			want: []sqlToken{
+				{Kind: tokenString, Text: "x'00ff'", Offset: 0},
+				{Kind: tokenString, Text: "X'AB'", Offset: 8},
+			},
+		},
+		{
+			name: "quoted identifiers",
+			sql:  `"a "" b" [c;d] ` + "`e``f`",
+			want: []sqlToken{
+				{Kind: tokenQuotedIdent, Text: `"a "" b"`, Offset: 0},
+				{Kind: tokenQuotedIdent, Text: "[c;d]", Offset: 9},
+				{Kind: tokenQuotedIdent, Text: "`e``f`", Offset: 15},
+			},
+		},
+		{
+			name: "comments are skipped",
+			sql:  "a -- b; c\n/* d; e */ f",
+			want: []sqlToken{
+				{Kind: tokenWord, Text: "a", Offset: 0},
+				{Kind: tokenWord, Text: "f", Offset: 21},
+			},
+		},
+		{
+			// SQLite block comments do not nest: the first */ ends the comment
+			name: "block comments do not nest",
+			sql:  "/* a /* b */ c */",
+			want: []sqlToken{
+				{Kind: tokenWord, Text: "c", Offset: 13},
+				{Kind: tokenPunct, Text: "*", Offset: 15},
+				{Kind: tokenPunct, Text: "/", Offset: 16},
+			},
+		},
+		{
+			name: "parameters",
+			sql:  "? ?12 :name @n $x1 :",
+			want: []sqlToken{
+				{Kind: tokenParam, Text: "?", Offset: 0},
+				{Kind: tokenParam, Text: "?12", Offset: 2},
+				{Kind: tokenParam, Text: ":name", Offset: 6},
+				{Kind: tokenParam, Text: "@n", Offset: 12},
+				{Kind: tokenParam, Text: "$x1", Offset: 15},
+				{Kind: tokenPunct, Text: ":", Offset: 19},
+			},
+		},
+		{
+			name: "unterminated literal runs to the end",
+			sql:  "SELECT 'abc",
+			want: []sqlToken{
+				{Kind: tokenWord, Text: "SELECT", Offset: 0},
+				{Kind: tokenString, Text: "'abc", Offset: 7},
+			},
+		},
+		{
+			name: "unterminated comment runs to the end",
+			sql:  "SELECT /* abc",
+			want: []sqlToken{
+				{Kind: tokenWord, Text: "SELECT", Offset: 0},
+			},
+		},
+		{
+			name: "non-ASCII identifier",
+			sql:  "SELECT größe",
+			want: []sqlToken{
+				{Kind: tokenWord, Text: "SELECT", Offset: 0},
+				{Kind: tokenWord, Text: "größe", Offset: 7},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tokenizeSQL(tt.sql)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tokenizeSQL(%q)\n got %+v\nwant %+v", tt.sql, got, tt.want)
+			}
+		})
+	}
+}
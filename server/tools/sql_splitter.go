@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"strings"
+)
+
+// sqlStatement - A single statement of a script and where it starts in the source
+type sqlStatement struct {
+	Text string
+	// Offset is the byte offset of the statement's first token in the script
+	Offset int
+	// Line is the 1-based line number of the statement's first token
+	Line int
+}
+
+// splitStatements - Split a script into statements on top-level semicolons.
+// Semicolons inside literals, quoted identifiers, comments and
+// CREATE TRIGGER ... BEGIN ... END bodies do not end a statement.
+func splitStatements(script string) []sqlStatement {
+	return splitTokens(script, tokenizeSQL(script))
+}
+
+// splitTokens - Group tokens of script into statements
+func splitTokens(script string, tokens []sqlToken) []sqlStatement {
+	var statements []sqlStatement
+
+	line := 1
+	lineOffset := 0
+	lineAt := func(offset int) int {
+		line += strings.Count(script[lineOffset:offset], "\n")
+		lineOffset = offset
+		return line
+	}
+
+	start := -1
+	var current []sqlToken
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		last := current[len(current)-1]
+		statements = append(statements, sqlStatement{
+			Text:   script[current[0].Offset : last.Offset+len(last.Text)],
+			Offset: current[0].Offset,
+			Line:   lineAt(current[0].Offset),
+		})
+		current = current[:0]
+		start = -1
+	}
+
+	isTrigger := false
+	inBody := false
+	caseDepth := 0
+
+	for i, token := range tokens {
+		if token.Kind == tokenSemicolon && !inBody {
+			flush()
+			isTrigger = false
+			continue
+		}
+
+		if start < 0 {
+			start = i
+			isTrigger = isCreateTrigger(tokens[i:])
+		}
+		current = append(current, token)
+
+		if !isTrigger || token.Kind != tokenWord {
+			continue
+		}
+
+		// Track the trigger body so that its inner statements stay together.
+		// CASE ... END expressions inside the body also end with END.
+		switch {
+		case !inBody && token.isKeyword("BEGIN"):
+			inBody = true
+			caseDepth = 0
+		case inBody && token.isKeyword("CASE"):
+			caseDepth++
+		case inBody && token.isKeyword("END"):
+			if caseDepth > 0 {
+				caseDepth--
+			} else {
+				inBody = false
+			}
+		}
+	}
+	flush()
+
+	return statements
+}
+
+// isCreateTrigger - Check whether the tokens start a CREATE [TEMP] TRIGGER statement
+func isCreateTrigger(tokens []sqlToken) bool {
+	if len(tokens) < 2 || !tokens[0].isKeyword("CREATE") {
+		return false
+	}
+	next := tokens[1]
+	if (next.isKeyword("TEMP") || next.isKeyword("TEMPORARY")) && len(tokens) > 2 {
+		next = tokens[2]
+	}
+	return next.isKeyword("TRIGGER")
+}
@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []sqlStatement
+	}{
+		{
+			name:   "empty script",
+			script: " ;\n-- only a comment\n;",
+			want:   nil,
+		},
+		{
+			name:   "single statement without semicolon",
+			script: "SELECT 1",
+			want:   []sqlStatement{{Text: "SELECT 1", Offset: 0, Line: 1}},
+		},
+		{
+			name:   "statements on several lines",
+			script: "INSERT INTO t VALUES (1);\n\n  UPDATE t SET a = 2;\nDELETE FROM t",
+			want: []sqlStatement{
+				{Text: "INSERT INTO t VALUES (1)", Offset: 0, Line: 1},
+				{Text: "UPDATE t SET a = 2", Offset: 29, Line: 3},
+				{Text: "DELETE FROM t", Offset: 49, Line: 4},
+			},
+		},
+		{
+			name:   "leading comment is not part of the statement",
+			script: "-- first\n/* second\nline */ SELECT 1; SELECT 2",
+			want: []sqlStatement{
+				{Text: "SELECT 1", Offset: 27, Line: 3},
+				{Text: "SELECT 2", Offset: 37, Line: 3},
+			},
+		},
+		{
+			name:   "semicolons inside literals, identifiers and comments",
+			script: `INSERT INTO "a;b" VALUES ('x;y', [c;d]) /* ; */ -- ;` + "\n;SELECT 1",
+			want: []sqlStatement{
+				{Text: `INSERT INTO "a;b" VALUES ('x;y', [c;d])`, Offset: 0, Line: 1},
+				{Text: "SELECT 1", Offset: 54, Line: 2},
+			},
+		},
+		{
+			name: "trigger body with CASE",
+			script: "CREATE TRIGGER tr AFTER INSERT ON t BEGIN\n" +
+				"  UPDATE t SET a = CASE WHEN new.a > 0 THEN 1 ELSE 0 END WHERE id = new.id;\n" +
+				"  DELETE FROM log;\n" +
+				"END;\n" +
+				"INSERT INTO t VALUES (1)",
+			want: []sqlStatement{
+				{
+					Text: "CREATE TRIGGER tr AFTER INSERT ON t BEGIN\n" +
+						"  UPDATE t SET a = CASE WHEN new.a > 0 THEN 1 ELSE 0 END WHERE id = new.id;\n" +
+						"  DELETE FROM log;\n" +
+						"END",
+					Offset: 0,
+					Line:   1,
+				},
+				{Text: "INSERT INTO t VALUES (1)", Offset: 142, Line: 5},
+			},
+		},
+		{
+			name:   "temp trigger with nested CASE",
+			script: "CREATE TEMP TRIGGER tr BEFORE DELETE ON t BEGIN SELECT CASE WHEN 1 THEN CASE 2 WHEN 2 THEN 3 END END; END; SELECT 1",
+			want: []sqlStatement{
+				{Text: "CREATE TEMP TRIGGER tr BEFORE DELETE ON t BEGIN SELECT CASE WHEN 1 THEN CASE 2 WHEN 2 THEN 3 END END; END", Offset: 0, Line: 1},
+				{Text: "SELECT 1", Offset: 107, Line: 1},
+			},
+		},
+		{
+			name:   "BEGIN outside of a trigger is a statement of its own",
+			script: "BEGIN; INSERT INTO t VALUES (1); COMMIT",
+			want: []sqlStatement{
+				{Text: "BEGIN", Offset: 0, Line: 1},
+				{Text: "INSERT INTO t VALUES (1)", Offset: 7, Line: 1},
+				{Text: "COMMIT", Offset: 33, Line: 1},
+			},
+		},
+		{
+			name:   "lines counted through multi-line literals",
+			script: "INSERT INTO t VALUES ('a\nb\nc');\nSELECT 1",
+			want: []sqlStatement{
+				{Text: "INSERT INTO t VALUES ('a\nb\nc')", Offset: 0, Line: 1},
+				{Text: "SELECT 1", Offset: 32, Line: 4},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitStatements(tt.script)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements(%q)\n got %+v\nwant %+v", tt.script, got, tt.want)
+			}
+			for _, statement := range got {
+				if tt.script[statement.Offset:statement.Offset+len(statement.Text)] != statement.Text {
+					t.Errorf("statement %q is not at offset %d", statement.Text, statement.Offset)
+				}
+			}
+		})
+	}
+}
+
+func TestIsCreateTrigger(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"CREATE TRIGGER tr", true},
+		{"create temp trigger tr", true},
+		{"CREATE TEMPORARY TRIGGER tr", true},
+		{"CREATE TABLE t", false},
+		{"CREATE TEMP TABLE t", false},
+		{"DROP TRIGGER tr", false},
+		{"CREATE", false},
+	}
+
+	for _, tt := range tests {
+		if got := isCreateTrigger(tokenizeSQL(tt.sql)); got != tt.want {
+			t.Errorf("isCreateTrigger(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
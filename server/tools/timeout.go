@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cnosuke/mcp-sqlite/config"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mattn/go-sqlite3"
+)
+
+// timeoutDescription - Shared description of the timeout_ms tool argument
+const timeoutDescription = "Optional timeout in milliseconds. Statements still running when it expires are interrupted and the call fails with \"query cancelled: timeout\""
+
+// cancelledError - Structured error returned when a statement was interrupted
+type cancelledError struct {
+	Error string `json:"error"`
+	// Reason is timeout when the deadline expired, cancelled when the client went away and step_limit
+	// when the statement ran more VM steps than limits.vm_steps allows
+	Reason    string `json:"reason"`
+	TimeoutMS int64  `json:"timeout_ms,omitempty"`
+}
+
+// callTimeout - Resolve the timeout of one tool call; 0 means the call has no timeout
+func callTimeout(cfg *config.Config, request mcp.CallToolRequest) (time.Duration, error) {
+	timeout := cfg.Query.Timeout
+
+	if ms := request.GetInt("timeout_ms", 0); ms != 0 {
+		if ms < 0 {
+			return 0, fmt.Errorf("timeout_ms must be positive")
+		}
+		timeout = time.Duration(ms) * time.Millisecond
+		if maxTimeout := cfg.Query.MaxTimeout; maxTimeout > 0 && timeout > maxTimeout {
+			return 0, fmt.Errorf("timeout_ms must not exceed %d", maxTimeout.Milliseconds())
+		}
+	}
+
+	if timeout < 0 {
+		return 0, nil
+	}
+	return timeout, nil
+}
+
+// withCallTimeout - Derive the context the statements of a tool call run in
+func withCallTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// cancelledResult - Build the structured error for a call whose context has ended, or nil if it is still live
+func cancelledResult(ctx context.Context, timeout time.Duration) *mcp.CallToolResult {
+	if ctx.Err() == nil {
+		return nil
+	}
+
+	body := cancelledError{Error: "query cancelled: timeout", Reason: "timeout", TimeoutMS: timeout.Milliseconds()}
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		body = cancelledError{Error: "query cancelled: request cancelled", Reason: "cancelled"}
+	}
+	return cancelledErrorResult(body)
+}
+
+// interruptedResult - Build the structured error for a statement interrupted by the deadline, by the client
+// going away or by the step limit, or nil if the statement failed otherwise
+func interruptedResult(ctx context.Context, timeout time.Duration, err error) *mcp.CallToolResult {
+	if result := cancelledResult(ctx, timeout); result != nil {
+		return result
+	}
+	// With the context still live, only the progress handler of limits.vm_steps interrupts a statement
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrInterrupt {
+		return cancelledErrorResult(cancelledError{Error: "query cancelled: step limit exceeded", Reason: "step_limit"})
+	}
+	return nil
+}
+
+// cancelledErrorResult - Return the structured error both as text and as structured content
+func cancelledErrorResult(body cancelledError) *mcp.CallToolResult {
+	result, err := jsonToolResult(body)
+	if err != nil {
+		return mcp.NewToolResultError(body.Error)
+	}
+	result.IsError = true
+	result.StructuredContent = body
+	return result
+}
+
+// errorResult - Turn a statement error into a tool result, reporting interrupted statements as cancelled
+func errorResult(ctx context.Context, timeout time.Duration, err error) *mcp.CallToolResult {
+	if result := interruptedResult(ctx, timeout, err); result != nil {
+		return result
+	}
+	return mcp.NewToolResultError(err.Error())
+}
@@ -1,35 +1,88 @@
 package tools
 
 import (
-	"database/sql"
-
+	"github.com/cnosuke/mcp-sqlite/config"
 	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
 )
 
 // RegisterAllTools - Register all tools with the server
-func RegisterAllTools(mcpServer *server.MCPServer, db *sql.DB) error {
+func RegisterAllTools(mcpServer *server.MCPServer, databases *Databases, cfg *config.Config) error {
 	// Register read_query tool
-	if err := RegisterReadQueryTool(mcpServer, db); err != nil {
+	if err := RegisterReadQueryTool(mcpServer, databases, cfg); err != nil {
 		return err
 	}
 
-	// Register write_query tool
-	if err := RegisterWriteQueryTool(mcpServer, db); err != nil {
-		return err
+	// Mutating tools are not exposed when every database is read-only
+	if !databases.AnyWritable() {
+		zap.S().Info("read-only mode enabled, skipping write_query, create_table, schema_change and import_data tools")
+	} else {
+		// Register write_query tool
+		if err := RegisterWriteQueryTool(mcpServer, databases, cfg); err != nil {
+			return err
+		}
+
+		// Register create_table tool
+		if err := RegisterCreateTableTool(mcpServer, databases, cfg); err != nil {
+			return err
+		}
+
+		// Register schema_change tool
+		if err := RegisterSchemaChangeTool(mcpServer, databases, cfg); err != nil {
+			return err
+		}
+
+		// Register import_data tool
+		if err := RegisterImportDataTool(mcpServer, databases, cfg); err != nil {
+			return err
+		}
 	}
 
-	// Register create_table tool
-	if err := RegisterCreateTableTool(mcpServer, db); err != nil {
+	// Register begin_transaction, commit_transaction, rollback_transaction and savepoint tools
+	if err := RegisterTransactionTools(mcpServer, databases, cfg); err != nil {
 		return err
 	}
 
 	// Register list_tables tool
-	if err := RegisterListTablesTools(mcpServer, db); err != nil {
+	if err := RegisterListTablesTools(mcpServer, databases, cfg); err != nil {
 		return err
 	}
 
 	// Register describe_table tool
-	if err := RegisterDescribeTableTool(mcpServer, db); err != nil {
+	if err := RegisterDescribeTableTool(mcpServer, databases, cfg); err != nil {
+		return err
+	}
+
+	// attach_database is only useful when some database allows attaching files
+	if databases.AnyAttachable() {
+		if err := RegisterAttachDatabaseTools(mcpServer, databases, cfg); err != nil {
+			return err
+		}
+	}
+
+	// backup_database and restore_database are only registered when some database allows backups
+	if databases.AnyBackups() {
+		if err := RegisterBackupTools(mcpServer, databases, cfg); err != nil {
+			return err
+		}
+	}
+
+	// export_query is only registered when some database allows exports
+	if databases.AnyExports() {
+		if err := RegisterExportQueryTool(mcpServer, databases, cfg); err != nil {
+			return err
+		}
+	}
+
+	// migration_status and apply_migrations are only useful when some database has migrations
+	if databases.AnyMigrations() {
+		if err := RegisterMigrationTools(mcpServer, databases, cfg); err != nil {
+			return err
+		}
+	}
+
+	// Register list_databases tool
+	if err := RegisterListDatabasesTool(mcpServer, databases); err != nil {
 		return err
 	}
 
@@ -0,0 +1,291 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cnosuke/mcp-sqlite/config"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// Actions of the savepoint tool
+const (
+	SavepointCreate     = "create"
+	SavepointRelease    = "release"
+	SavepointRollbackTo = "rollback_to"
+)
+
+// BeginTransactionArgs - Arguments for begin_transaction tool (kept for testing compatibility)
+type BeginTransactionArgs struct {
+	Mode           string `json:"mode,omitempty" jsonschema:"description=Transaction mode: deferred, immediate or exclusive"`
+	AutoRollbackMS int    `json:"auto_rollback_ms,omitempty" jsonschema:"description=Roll the transaction back after this many milliseconds without a call using it"`
+}
+
+// EndTransactionArgs - Arguments for commit_transaction and rollback_transaction tools (kept for testing compatibility)
+type EndTransactionArgs struct {
+	Transaction string `json:"transaction" jsonschema:"description=Handle returned by begin_transaction"`
+}
+
+// SavepointArgs - Arguments for savepoint tool (kept for testing compatibility)
+type SavepointArgs struct {
+	Transaction string `json:"transaction" jsonschema:"description=Handle returned by begin_transaction"`
+	Name        string `json:"name" jsonschema:"description=Name of the savepoint"`
+	Action      string `json:"action,omitempty" jsonschema:"description=create, release or rollback_to"`
+}
+
+// RegisterTransactionTools - Register the begin_transaction, commit_transaction, rollback_transaction and savepoint tools
+func RegisterTransactionTools(mcpServer *server.MCPServer, databases *Databases, cfg *config.Config) error {
+	zap.S().Debug("registering transaction tools")
+
+	if cfg.Transaction.AutoRollback <= 0 {
+		return fmt.Errorf("transaction.auto_rollback must be positive, got %s", cfg.Transaction.AutoRollback)
+	}
+
+	// Define the begin tool
+	beginTool := mcp.NewTool("begin_transaction",
+		mcp.WithDescription("Begin a transaction that spans several tool calls. Pass the returned handle as the transaction argument of read_query and write_query "+
+			"to work inside it, then end it with commit_transaction or rollback_transaction. "+
+			"The transaction runs on the session's connection and sees its TEMP tables; until it ends, calls of the session on the same database must pass the handle. "+
+			fmt.Sprintf("A transaction that goes unused for %s is rolled back automatically", cfg.Transaction.AutoRollback)),
+		mcp.WithString("mode",
+			mcp.Description("Transaction mode (default deferred). immediate and exclusive take the write lock right away"),
+			mcp.Enum(TransactionModes...),
+		),
+		mcp.WithNumber("auto_rollback_ms",
+			mcp.Description(fmt.Sprintf("Roll the transaction back after this many milliseconds without a call using it (default and maximum %d)", cfg.Transaction.AutoRollback.Milliseconds())),
+			mcp.Min(1),
+		),
+		mcp.WithNumber("timeout_ms",
+			mcp.Description(timeoutDescription),
+			mcp.Min(1),
+		),
+		databaseArgument(databases),
+	)
+
+	mcpServer.AddTool(beginTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		mode := strings.ToLower(request.GetString("mode", TransactionDeferred))
+		if !isTransactionMode(mode) {
+			return mcp.NewToolResultError(fmt.Sprintf("unsupported transaction mode: %s", mode)), nil
+		}
+		autoRollback := cfg.Transaction.AutoRollback
+		if ms := request.GetInt("auto_rollback_ms", 0); ms != 0 {
+			requested := time.Duration(ms) * time.Millisecond
+			if requested <= 0 || requested > cfg.Transaction.AutoRollback {
+				return mcp.NewToolResultError(fmt.Sprintf("auto_rollback_ms must be between 1 and %d", cfg.Transaction.AutoRollback.Milliseconds())), nil
+			}
+			autoRollback = requested
+		}
+
+		database, err := requestDatabase(databases, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		timeout, err := callTimeout(cfg, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx, cancel := withCallTimeout(ctx, timeout)
+		defer cancel()
+
+		zap.S().Debugw("executing begin_transaction",
+			"database", database.Name,
+			"mode", mode,
+			"auto_rollback", autoRollback)
+
+		t, err := databases.beginTransaction(ctx, database, mode, autoRollback)
+		if err != nil {
+			zap.S().Errorw("failed to begin transaction",
+				"database", database.Name,
+				"error", err)
+			return errorResult(ctx, timeout, err), nil
+		}
+		zap.S().Infow("transaction started",
+			"transaction", t.ID,
+			"database", database.Name,
+			"mode", mode)
+
+		return transactionResult(t.info("open"))
+	})
+
+	// Define the commit and rollback tools
+	for _, commit := range []bool{true, false} {
+		name, verb := "rollback_transaction", "Roll back"
+		if commit {
+			name, verb = "commit_transaction", "Commit"
+		}
+
+		endTool := mcp.NewTool(name,
+			mcp.WithDescription(fmt.Sprintf("%s a transaction started by begin_transaction and release its handle", verb)),
+			mcp.WithString("transaction",
+				mcp.Description("Handle returned by begin_transaction"),
+				mcp.Required(),
+			),
+			mcp.WithNumber("timeout_ms",
+				mcp.Description(timeoutDescription),
+				mcp.Min(1),
+			),
+		)
+
+		mcpServer.AddTool(endTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			handle := request.GetString("transaction", "")
+			if handle == "" {
+				return mcp.NewToolResultError("transaction parameter is required"), nil
+			}
+
+			timeout, err := callTimeout(cfg, request)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			ctx, cancel := withCallTimeout(ctx, timeout)
+			defer cancel()
+
+			zap.S().Debugw("executing "+name, "transaction", handle)
+
+			t, err := databases.acquireTransaction(ctx, handle)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			defer databases.releaseTransaction(t)
+
+			if err := databases.endTransaction(ctx, t, commit); err != nil {
+				zap.S().Errorw("failed to end transaction",
+					"transaction", handle,
+					"commit", commit,
+					"error", err)
+				return errorResult(ctx, timeout, err), nil
+			}
+
+			state := "rolled_back"
+			if commit {
+				state = "committed"
+			}
+			zap.S().Infow("transaction ended", "transaction", handle, "state", state)
+
+			info := t.info(state)
+			info.Savepoints = []string{}
+			return transactionResult(info)
+		})
+	}
+
+	// Define the savepoint tool
+	savepointTool := mcp.NewTool("savepoint",
+		mcp.WithDescription("Create, release or roll back to a savepoint inside a transaction started by begin_transaction"),
+		mcp.WithString("transaction",
+			mcp.Description("Handle returned by begin_transaction"),
+			mcp.Required(),
+		),
+		mcp.WithString("name",
+			mcp.Description("Name of the savepoint"),
+			mcp.Required(),
+		),
+		mcp.WithString("action",
+			mcp.Description("create (default) opens a savepoint, release keeps its changes and forgets it, rollback_to undoes the changes made since it and keeps it open"),
+			mcp.Enum(SavepointCreate, SavepointRelease, SavepointRollbackTo),
+		),
+		mcp.WithNumber("timeout_ms",
+			mcp.Description(timeoutDescription),
+			mcp.Min(1),
+		),
+	)
+
+	mcpServer.AddTool(savepointTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handle := request.GetString("transaction", "")
+		name := request.GetString("name", "")
+		action := request.GetString("action", SavepointCreate)
+		if handle == "" {
+			return mcp.NewToolResultError("transaction parameter is required"), nil
+		}
+		if name == "" {
+			return mcp.NewToolResultError("name parameter is required"), nil
+		}
+
+		timeout, err := callTimeout(cfg, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx, cancel := withCallTimeout(ctx, timeout)
+		defer cancel()
+
+		zap.S().Debugw("executing savepoint",
+			"transaction", handle,
+			"name", name,
+			"action", action)
+
+		t, err := databases.acquireTransaction(ctx, handle)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		defer databases.releaseTransaction(t)
+
+		// Savepoint names match case-insensitively; the innermost one wins
+		index := -1
+		for i := len(t.savepoints) - 1; i >= 0; i-- {
+			if strings.EqualFold(t.savepoints[i], name) {
+				index = i
+				break
+			}
+		}
+
+		var statement string
+		switch action {
+		case SavepointCreate:
+			statement = "SAVEPOINT " + quoteIdentifier(name)
+		case SavepointRelease:
+			statement = "RELEASE SAVEPOINT " + quoteIdentifier(name)
+		case SavepointRollbackTo:
+			statement = "ROLLBACK TO SAVEPOINT " + quoteIdentifier(name)
+		default:
+			return mcp.NewToolResultError(fmt.Sprintf("unsupported savepoint action: %s", action)), nil
+		}
+		if action != SavepointCreate && index < 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("no such savepoint: %s (open: %v)", name, t.savepoints)), nil
+		}
+
+		if _, err := t.conn.ExecContext(ctx, statement); err != nil {
+			zap.S().Errorw("failed to execute savepoint",
+				"transaction", handle,
+				"statement", statement,
+				"error", err)
+			return errorResult(ctx, timeout, err), nil
+		}
+
+		switch action {
+		case SavepointCreate:
+			t.savepoints = append(t.savepoints, name)
+		case SavepointRelease:
+			// Releasing a savepoint also releases the ones opened after it
+			t.savepoints = t.savepoints[:index]
+		case SavepointRollbackTo:
+			t.savepoints = t.savepoints[:index+1]
+		}
+
+		return transactionResult(t.info("open"))
+	})
+
+	return nil
+}
+
+// isTransactionMode - Check whether the transaction mode is supported
+func isTransactionMode(mode string) bool {
+	for _, m := range TransactionModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// transactionResult - Convert a transaction description into a tool result
+func transactionResult(info transactionInfo) (*mcp.CallToolResult, error) {
+	result, err := jsonToolResult(info)
+	if err != nil {
+		zap.S().Errorw("failed to convert result to JSON", "error", err)
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return result, nil
+}
@@ -0,0 +1,399 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+)
+
+// Transaction modes accepted by begin_transaction
+const (
+	TransactionDeferred  = "deferred"
+	TransactionImmediate = "immediate"
+	TransactionExclusive = "exclusive"
+)
+
+// TransactionModes - All supported transaction modes
+var TransactionModes = []string{TransactionDeferred, TransactionImmediate, TransactionExclusive}
+
+// transaction - A transaction opened by begin_transaction on the connection pinned to its session,
+// or on a connection of its own outside of a session
+type transaction struct {
+	ID           string
+	Database     *Database
+	Mode         string
+	SessionID    string
+	AutoRollback time.Duration
+
+	conn *sql.Conn
+	// session is the pinned connection the transaction runs on, nil when it has a connection of its own
+	session *sessionConn
+	// busy holds a token while a tool call uses the transaction; it is the session connection's busy
+	busy     chan struct{}
+	timer    *time.Timer
+	lastUsed time.Time
+	// savepoints are the open savepoints, innermost last; guarded by busy
+	savepoints []string
+	// closed is set once the transaction ended; guarded by busy
+	closed bool
+}
+
+// maxEndedTransactions - Number of ended transactions whose outcome is remembered for error messages
+const maxEndedTransactions = 1000
+
+// transactionRegistry - Open transaction handles by ID
+type transactionRegistry struct {
+	mu   sync.Mutex
+	byID map[string]*transaction
+	// ended records how recently ended transactions ended, e.g. "committed"
+	ended map[string]string
+}
+
+// unknownTransactionError - Error for a handle that is not open, naming how it ended when known
+func (r *transactionRegistry) unknownTransactionError(id string) error {
+	if outcome, ok := r.ended[id]; ok {
+		return fmt.Errorf("transaction %s is no longer open: %s", id, outcome)
+	}
+	return fmt.Errorf("unknown transaction %s", id)
+}
+
+// transactionInfo - Response body describing a transaction handle
+type transactionInfo struct {
+	Transaction    string   `json:"transaction"`
+	Database       string   `json:"database"`
+	Mode           string   `json:"mode,omitempty"`
+	Savepoints     []string `json:"savepoints"`
+	AutoRollbackMS int64    `json:"auto_rollback_ms,omitempty"`
+	State          string   `json:"state"`
+}
+
+// info - Describe the transaction in the given state
+func (t *transaction) info(state string) transactionInfo {
+	savepoints := append([]string{}, t.savepoints...)
+	return transactionInfo{
+		Transaction:    t.ID,
+		Database:       t.Database.Name,
+		Mode:           t.Mode,
+		Savepoints:     savepoints,
+		AutoRollbackMS: t.AutoRollback.Milliseconds(),
+		State:          state,
+	}
+}
+
+// newTransactionID - Random, unguessable transaction handle
+func newTransactionID() (string, error) {
+	return newHandle("tx_")
+}
+
+// newHandle - Random, unguessable handle with the given prefix
+func newHandle(prefix string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
+
+// beginTransaction - Open a transaction and register its handle. Within a client session the transaction
+// runs on the session's pinned connection, so it sees the session's TEMP tables and connection settings,
+// and calls of the session without the handle, other than schema inspection, are refused until it ends.
+// Outside of a session it gets a pooled connection of its own.
+func (d *Databases) beginTransaction(ctx context.Context, database *Database, mode string, autoRollback time.Duration) (*transaction, error) {
+	id, err := newTransactionID()
+	if err != nil {
+		return nil, err
+	}
+	t := &transaction{
+		ID:           id,
+		Database:     database,
+		Mode:         mode,
+		SessionID:    sessionIDFromContext(ctx),
+		AutoRollback: autoRollback,
+		lastUsed:     time.Now(),
+	}
+
+	if t.SessionID != "" && database.IdleTimeout > 0 {
+		pinned, err := database.acquireSessionConn(ctx, t.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		if pinned.tx != nil {
+			open := pinned.tx.ID
+			<-pinned.busy
+			return nil, fmt.Errorf("transaction %s is already open in this session on database %s; use savepoint to nest transactions", open, database.Name)
+		}
+		if err := beginOn(ctx, database, pinned.conn, mode); err != nil {
+			database.releaseSessionConn(t.SessionID, pinned)
+			return nil, err
+		}
+		pinned.tx = t
+		t.conn, t.session, t.busy = pinned.conn, pinned, pinned.busy
+		// The idle timer stays stopped while the transaction is open
+		defer func() { <-pinned.busy }()
+	} else {
+		if database.inMemory() {
+			return nil, fmt.Errorf("database %s is in memory, where every connection opens a database of its own; "+
+				"begin_transaction needs a client session with a pinned connection (session.idle_timeout above 0)", database.Name)
+		}
+		conn, err := database.DB.Conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := beginOn(ctx, database, conn, mode); err != nil {
+			discardConn(conn)
+			return nil, err
+		}
+		t.conn, t.busy = conn, make(chan struct{}, 1)
+	}
+
+	t.timer = time.AfterFunc(autoRollback, func() { d.expireTransaction(t) })
+
+	d.transactions.mu.Lock()
+	if d.transactions.byID == nil {
+		d.transactions.byID = make(map[string]*transaction)
+	}
+	d.transactions.byID[id] = t
+	d.transactions.mu.Unlock()
+
+	return t, nil
+}
+
+// beginOn - Apply the current attachments to a connection and open a transaction on it
+func beginOn(ctx context.Context, database *Database, conn *sql.Conn, mode string) error {
+	if err := database.syncAttachments(ctx, conn); err != nil {
+		return err
+	}
+	_, err := conn.ExecContext(ctx, "BEGIN "+strings.ToUpper(mode))
+	return err
+}
+
+// acquireTransaction - Look up a transaction handle and wait until no other call is using it
+func (d *Databases) acquireTransaction(ctx context.Context, id string) (*transaction, error) {
+	d.transactions.mu.Lock()
+	t, ok := d.transactions.byID[id]
+	if !ok {
+		err := d.transactions.unknownTransactionError(id)
+		d.transactions.mu.Unlock()
+		return nil, err
+	}
+	d.transactions.mu.Unlock()
+	if t.SessionID != sessionIDFromContext(ctx) {
+		return nil, fmt.Errorf("transaction %s belongs to a different session", id)
+	}
+
+	select {
+	case t.busy <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if t.closed {
+		<-t.busy
+		d.transactions.mu.Lock()
+		defer d.transactions.mu.Unlock()
+		return nil, d.transactions.unknownTransactionError(id)
+	}
+	t.timer.Stop()
+	return t, nil
+}
+
+// releaseTransaction - Hand the transaction back after a call and restart its auto-rollback timer.
+// SQLite itself ends the transaction when an interrupted write rolls it back, so the handle is
+// dropped when the connection is found back in autocommit mode.
+func (d *Databases) releaseTransaction(t *transaction) {
+	defer func() { <-t.busy }()
+
+	if !t.closed && connAutoCommit(t.conn) {
+		zap.S().Warnw("transaction ended by SQLite",
+			"transaction", t.ID,
+			"database", t.Database.Name)
+		d.closeTransaction(t, "rolled back by SQLite after a statement failed or was interrupted")
+		return
+	}
+	if !t.closed {
+		t.lastUsed = time.Now()
+		t.timer.Reset(t.AutoRollback)
+	}
+}
+
+// endTransaction - COMMIT or ROLLBACK a transaction the caller holds. A failed COMMIT keeps
+// the transaction open when SQLite does, e.g. while another connection holds a lock.
+func (d *Databases) endTransaction(ctx context.Context, t *transaction, commit bool) error {
+	statement := "ROLLBACK"
+	if commit {
+		statement = "COMMIT"
+	}
+	if _, err := t.conn.ExecContext(ctx, statement); err != nil {
+		if connAutoCommit(t.conn) {
+			d.closeTransaction(t, fmt.Sprintf("rolled back after %s failed", statement))
+		}
+		return err
+	}
+	if commit {
+		d.closeTransaction(t, "committed")
+	} else {
+		d.closeTransaction(t, "rolled back")
+	}
+	return nil
+}
+
+// expireTransaction - Roll back a transaction that has not been used for its auto-rollback timeout
+func (d *Databases) expireTransaction(t *transaction) {
+	t.busy <- struct{}{}
+	defer func() { <-t.busy }()
+
+	// A call may have taken the transaction just before the timer fired
+	if t.closed || time.Since(t.lastUsed) < t.AutoRollback {
+		return
+	}
+	zap.S().Warnw("rolling back unused transaction",
+		"transaction", t.ID,
+		"database", t.Database.Name,
+		"auto_rollback", t.AutoRollback)
+	d.rollbackHeld(t, fmt.Sprintf("rolled back automatically after %s without use", t.AutoRollback))
+}
+
+// rollbackHeld - Roll back and close a transaction the caller holds, logging failures
+func (d *Databases) rollbackHeld(t *transaction, outcome string) {
+	if _, err := t.conn.ExecContext(context.Background(), "ROLLBACK"); err != nil && !connAutoCommit(t.conn) {
+		zap.S().Errorw("failed to roll back transaction",
+			"transaction", t.ID,
+			"error", err)
+	}
+	d.closeTransaction(t, outcome)
+}
+
+// closeTransaction - Forget a transaction, remembering its outcome, and hand the session connection
+// it ran on back to the session, or discard its own connection. The caller holds busy.
+func (d *Databases) closeTransaction(t *transaction, outcome string) {
+	d.transactions.mu.Lock()
+	delete(d.transactions.byID, t.ID)
+	if d.transactions.ended == nil {
+		d.transactions.ended = make(map[string]string)
+	}
+	if len(d.transactions.ended) >= maxEndedTransactions {
+		for id := range d.transactions.ended {
+			delete(d.transactions.ended, id)
+			break
+		}
+	}
+	d.transactions.ended[t.ID] = outcome
+	d.transactions.mu.Unlock()
+
+	t.closed = true
+	t.timer.Stop()
+	if t.session == nil {
+		discardConn(t.conn)
+		return
+	}
+	t.session.tx = nil
+	if !connAutoCommit(t.conn) {
+		// The ROLLBACK failed, and later calls of the session must not run inside the transaction
+		t.Database.discardSessionConn(t.SessionID, t.session)
+		return
+	}
+	t.Database.restartIdleTimer(t.SessionID, t.session)
+}
+
+// rollbackTransactions - Roll back the open transactions selected by the filter
+func (d *Databases) rollbackTransactions(filter func(*transaction) bool, outcome string) {
+	d.transactions.mu.Lock()
+	var selected []*transaction
+	for _, t := range d.transactions.byID {
+		if filter(t) {
+			selected = append(selected, t)
+		}
+	}
+	d.transactions.mu.Unlock()
+
+	for _, t := range selected {
+		t.busy <- struct{}{}
+		if !t.closed {
+			zap.S().Infow("rolling back transaction", "transaction", t.ID, "database", t.Database.Name)
+			d.rollbackHeld(t, outcome)
+		}
+		<-t.busy
+	}
+}
+
+// RollbackTransactions - Roll back every open transaction, e.g. on shutdown
+func (d *Databases) RollbackTransactions() {
+	d.rollbackTransactions(func(*transaction) bool { return true }, "rolled back on shutdown")
+}
+
+// connAutoCommit - Check whether a connection is outside of any transaction
+func connAutoCommit(conn *sql.Conn) bool {
+	autoCommit := true
+	conn.Raw(func(driverConn any) error {
+		if sqliteConn, ok := driverConn.(*sqlite3.SQLiteConn); ok {
+			autoCommit = sqliteConn.AutoCommit()
+		}
+		return nil
+	})
+	return autoCommit
+}
+
+// transactionArgument - The optional transaction argument of the tools that can run inside a transaction
+func transactionArgument() mcp.ToolOption {
+	return mcp.WithString("transaction",
+		mcp.Description("Handle returned by begin_transaction. The call runs inside that transaction and sees its uncommitted changes"),
+	)
+}
+
+// callDatabase - Resolve the database of a call. With a transaction handle the database is the
+// transaction's, and a database argument naming another one is rejected.
+func callDatabase(databases *Databases, name, handle string, writable bool) (*Database, error) {
+	var database *Database
+	if handle != "" {
+		databases.transactions.mu.Lock()
+		t, ok := databases.transactions.byID[handle]
+		if !ok {
+			err := databases.transactions.unknownTransactionError(handle)
+			databases.transactions.mu.Unlock()
+			return nil, err
+		}
+		databases.transactions.mu.Unlock()
+		if name != "" && name != t.Database.Name {
+			return nil, fmt.Errorf("transaction %s belongs to database %s", handle, t.Database.Name)
+		}
+		database = t.Database
+	} else {
+		var err error
+		database, err = databases.Get(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if writable && database.ReadOnly {
+		return nil, fmt.Errorf("database %s is read-only", database.Name)
+	}
+	return database, nil
+}
+
+// callConn - The connection a call runs on: the transaction's when a handle is passed, otherwise
+// the one Database.Conn hands out. The returned function must be called when the call is done.
+func callConn(ctx context.Context, databases *Databases, database *Database, handle string) (*sql.Conn, func(), error) {
+	if handle == "" {
+		conn, release, err := database.Conn(ctx)
+		var open *openTransactionError
+		if errors.As(err, &open) {
+			open.argument = true
+		}
+		return conn, release, err
+	}
+	t, err := databases.acquireTransaction(ctx, handle)
+	if err != nil {
+		return nil, nil, err
+	}
+	return t.conn, func() { databases.releaseTransaction(t) }, nil
+}
@@ -4,8 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"strings"
+	"time"
 
+	"github.com/cnosuke/mcp-sqlite/config"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"go.uber.org/zap"
@@ -13,67 +14,179 @@ import (
 
 // WriteQueryArgs - Arguments for write_query tool (kept for testing compatibility)
 type WriteQueryArgs struct {
-	Query string `json:"query" jsonschema:"description=The SQL write query (INSERT, UPDATE, DELETE) to execute. Supports multiple statements separated by semicolons and transactions (BEGIN, COMMIT, ROLLBACK)"`
+	Query             string `json:"query" jsonschema:"description=The SQL write query (INSERT, REPLACE, UPDATE, DELETE) to execute. Supports multiple statements separated by semicolons and transactions (BEGIN, COMMIT, ROLLBACK)"`
+	Params            any    `json:"params,omitempty" jsonschema:"description=Optional positional (array) or named (object) bind parameters. Positional values are consumed by the statements in order; named values are shared by all statements"`
+	TimeoutMS         int    `json:"timeout_ms,omitempty" jsonschema:"description=Timeout in milliseconds after which the running statement is interrupted and the script rolled back"`
+	Transaction       string `json:"transaction,omitempty" jsonschema:"description=Handle returned by begin_transaction"`
+	DryRun            bool   `json:"dry_run,omitempty" jsonschema:"description=Run the statements and report the rows they would change, then roll everything back"`
+	ConfirmationToken string `json:"confirmation_token,omitempty" jsonschema:"description=Token returned when a destructive script was held back; repeat the same call with it to execute the script"`
 }
 
 // statementResult - 各ステートメントの実行結果
 type statementResult struct {
 	Statement    string
+	Offset       int
+	Line         int
 	Operation    string
 	Success      bool
 	RowsAffected int64
 	LastInsertID int64
-	Error        error
+	// Returning は RETURNING 句が返した行（RETURNING がない場合は nil）
+	Returning *returnedRows
+	Duration  time.Duration
+	Error     error
 }
 
-// splitQueries - クエリを複数のステートメントに分割
-func splitQueries(query string) []string {
-	// セミコロンで分割
-	statements := strings.Split(query, ";")
+// returnedRows - RETURNING 句が返した行
+type returnedRows struct {
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+	// Truncated は query.max_rows を超えた行を省略した場合に true
+	Truncated bool `json:"truncated"`
+}
 
-	// 空のステートメントを除去
-	var result []string
-	for _, stmt := range statements {
-		trimmed := strings.TrimSpace(stmt)
-		if trimmed != "" {
-			result = append(result, trimmed)
-		}
-	}
+// finish - 実行時間を記録した結果を返す
+func (r statementResult) finish(started time.Time) statementResult {
+	r.Duration = time.Since(started)
+	return r
+}
 
-	return result
+// statementOutcome - 各ステートメントの実行結果（JSON 応答用）
+type statementOutcome struct {
+	Statement    string        `json:"statement"`
+	Line         int           `json:"line"`
+	Offset       int           `json:"offset"`
+	Operation    string        `json:"operation"`
+	Success      bool          `json:"success"`
+	RowsAffected int64         `json:"rows_affected"`
+	LastInsertID int64         `json:"last_insert_id,omitempty"`
+	Returning    *returnedRows `json:"returning,omitempty"`
+	DurationMS   float64       `json:"duration_ms"`
+	Error        string        `json:"error,omitempty"`
 }
 
-// isValidWriteOperation - 有効な書き込み操作かどうかを確認し、操作タイプを返す
-func isValidWriteOperation(stmt string) (bool, string) {
-	upper := strings.ToUpper(strings.TrimSpace(stmt))
+// writeResult - write_query の応答
+type writeResult struct {
+	Summary    string             `json:"summary"`
+	Statements []statementOutcome `json:"statements"`
+	// Error はスクリプトを中断したエラー
+	Error string `json:"error,omitempty"`
+}
 
-	validTypes := []string{"INSERT", "UPDATE", "DELETE", "BEGIN", "COMMIT", "ROLLBACK"}
-	for _, validType := range validTypes {
-		if strings.HasPrefix(upper, validType) {
-			return true, validType
+// statementOutcomes - 実行結果を JSON 応答用に変換
+func statementOutcomes(results []statementResult) []statementOutcome {
+	outcomes := make([]statementOutcome, len(results))
+	for i, result := range results {
+		outcomes[i] = statementOutcome{
+			Statement:    result.Statement,
+			Line:         result.Line,
+			Offset:       result.Offset,
+			Operation:    result.Operation,
+			Success:      result.Success,
+			RowsAffected: result.RowsAffected,
+			LastInsertID: result.LastInsertID,
+			Returning:    result.Returning,
+			DurationMS:   float64(result.Duration.Microseconds()) / 1000,
+		}
+		if result.Error != nil {
+			outcomes[i].Error = result.Error.Error()
 		}
 	}
+	return outcomes
+}
 
-	return false, ""
+// writeOperation - 書き込み操作として許可されるか確認し、操作タイプを返す
+func writeOperation(class statementClass) (string, bool) {
+	switch {
+	case class.IsTransactionControl():
+		// END は COMMIT の別名
+		if class.Kind == "END" {
+			return "COMMIT", true
+		}
+		return class.Kind, true
+	case class.IsDML():
+		return class.Kind, true
+	}
+
+	return "", false
+}
+
+// executeOptions - executeStatements の実行条件
+type executeOptions struct {
+	// noTransactionControl が空でない場合、外側のトランザクション内で実行するため BEGIN/COMMIT/ROLLBACK は使えない。
+	// 値はその理由としてエラーメッセージに使う
+	noTransactionControl string
+	// capture が nil でない場合、ドライランとして変更された行を記録する
+	capture *changeCapture
+	// savepointTransactions が true の場合、BEGIN/COMMIT/ROLLBACK をセーブポイントで置き換える
+	// （ドライランは全体がセーブポイント内で実行されるため、本物のトランザクションは開始できない）
+	savepointTransactions bool
+	// risks が nil でない場合、確認が必要な危険なステートメントを記録する
+	risks *riskCollector
+	// encoder は RETURNING 句が返した値の変換に使う
+	encoder *valueEncoder
+	// maxReturnedRows はステートメントごとに返す RETURNING の行数の上限（0 以下は無制限）
+	maxReturnedRows int
 }
 
+// scriptSavepoint - ドライラン中にスクリプトの BEGIN が開くセーブポイント
+const scriptSavepoint = `"mcp_sqlite_script"`
+
 // executeStatements - 複数のステートメントを実行
-func executeStatements(ctx context.Context, db *sql.DB, stmts []string) ([]statementResult, error) {
+// 分類と実行は同じ接続で行う（トランザクション内で作成されたテーブルも参照できるように）。
+func executeStatements(ctx context.Context, conn *sql.Conn, stmts []sqlStatement, params *queryParams, opts executeOptions) ([]statementResult, error) {
 	var results []statementResult
 	var tx *sql.Tx
 	inTransaction := false
 
+	// エラーで中断した場合は未完了のトランザクションをロールバック
+	defer func() {
+		if tx != nil {
+			tx.Rollback()
+		}
+	}()
+
 	for i, stmt := range stmts {
-		valid, operationType := isValidWriteOperation(stmt)
+		class, err := classifyStatement(ctx, conn, stmt.Text)
+		if err != nil {
+			return results, fmt.Errorf("failed to prepare statement %d (line %d, offset %d): %w", i+1, stmt.Line, stmt.Offset, err)
+		}
+
+		operationType, valid := writeOperation(class)
 		if !valid {
-			return results, fmt.Errorf("statement %d is not a valid write operation: %s", i+1, stmt)
+			return results, fmt.Errorf("statement %d (line %d, offset %d) is not a valid write operation (%s): %s", i+1, stmt.Line, stmt.Offset, statementKindName(class), stmt.Text)
+		}
+		if opts.noTransactionControl != "" && class.IsTransactionControl() {
+			return results, fmt.Errorf("statement %d (line %d, offset %d): %s %s", i+1, stmt.Line, stmt.Offset, operationType, opts.noTransactionControl)
+		}
+
+		// 危険なステートメントの検出（実行前に分かるもの）
+		if opts.risks != nil {
+			if err := opts.risks.check(conn, i, stmt, class); err != nil {
+				return results, fmt.Errorf("failed to check statement %d (line %d, offset %d): %w", i+1, stmt.Line, stmt.Offset, err)
+			}
+		}
+
+		// バインドパラメータの割り当て
+		args, err := params.argsFor(class)
+		if err != nil {
+			return results, fmt.Errorf("invalid params for statement %d (line %d, offset %d): %w", i+1, stmt.Line, stmt.Offset, err)
+		}
+		// 最後のステートメントの実行前に、使われなかったパラメータがないか確認
+		if i == len(stmts)-1 {
+			if err := params.checkAllUsed(); err != nil {
+				return results, err
+			}
 		}
 
 		result := statementResult{
-			Statement: stmt,
+			Statement: stmt.Text,
+			Offset:    stmt.Offset,
+			Line:      stmt.Line,
 			Operation: operationType,
 			Success:   false,
 		}
+		started := time.Now()
 
 		// トランザクション処理
 		if operationType == "BEGIN" {
@@ -81,88 +194,137 @@ func executeStatements(ctx context.Context, db *sql.DB, stmts []string) ([]state
 				return results, fmt.Errorf("nested transactions are not supported")
 			}
 
-			var err error
-			tx, err = db.BeginTx(ctx, nil)
+			if opts.savepointTransactions {
+				_, err = conn.ExecContext(ctx, "SAVEPOINT "+scriptSavepoint)
+			} else {
+				tx, err = conn.BeginTx(ctx, nil)
+			}
 			if err != nil {
 				result.Error = err
-				results = append(results, result)
+				results = append(results, result.finish(started))
 				return results, fmt.Errorf("failed to begin transaction: %w", err)
 			}
 
 			inTransaction = true
 			result.Success = true
-			results = append(results, result)
+			results = append(results, result.finish(started))
 			continue
 		} else if operationType == "COMMIT" {
 			if !inTransaction {
 				return results, fmt.Errorf("COMMIT without BEGIN")
 			}
 
-			err := tx.Commit()
+			var err error
+			if opts.savepointTransactions {
+				_, err = conn.ExecContext(ctx, "RELEASE SAVEPOINT "+scriptSavepoint)
+			} else {
+				err = tx.Commit()
+			}
 			if err != nil {
 				result.Error = err
-				results = append(results, result)
+				results = append(results, result.finish(started))
 				return results, fmt.Errorf("failed to commit transaction: %w", err)
 			}
 
 			inTransaction = false
 			tx = nil
 			result.Success = true
-			results = append(results, result)
+			results = append(results, result.finish(started))
 			continue
 		} else if operationType == "ROLLBACK" {
 			if !inTransaction {
 				return results, fmt.Errorf("ROLLBACK without BEGIN")
 			}
 
-			err := tx.Rollback()
+			var err error
+			if opts.savepointTransactions {
+				_, err = conn.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+scriptSavepoint)
+				if err == nil {
+					_, err = conn.ExecContext(ctx, "RELEASE SAVEPOINT "+scriptSavepoint)
+				}
+			} else {
+				err = tx.Rollback()
+			}
 			if err != nil {
 				result.Error = err
-				results = append(results, result)
+				results = append(results, result.finish(started))
 				return results, fmt.Errorf("failed to rollback transaction: %w", err)
 			}
 
 			inTransaction = false
 			tx = nil
 			result.Success = true
-			results = append(results, result)
+			results = append(results, result.finish(started))
 			continue
 		}
 
-		// 通常のステートメント実行
+		// DELETE が削除する行数は実行前に数える。上限を超える場合は確認が必要になるので、残りは実行しない
+		if opts.risks != nil && class.Kind == "DELETE" {
+			var db queryer = conn
+			if tx != nil {
+				db = tx
+			}
+			exceeded, err := opts.risks.checkDeleteCount(ctx, db, i, stmt, args)
+			if err != nil {
+				return results, fmt.Errorf("failed to count the rows of statement %d (line %d, offset %d): %w", i+1, stmt.Line, stmt.Offset, err)
+			}
+			if exceeded {
+				return results, nil
+			}
+		}
+
+		// ドライランでは実行前に書き込み先のテーブルの変更記録を開始
+		if opts.capture != nil {
+			if err := opts.capture.watch(ctx, conn, stmt.Text); err != nil {
+				return results, fmt.Errorf("statement %d (line %d, offset %d): %w", i+1, stmt.Line, stmt.Offset, err)
+			}
+		}
+
+		// 通常のステートメント実行（RETURNING 句がある場合は返された行も読む）
 		var sqlResult sql.Result
-		var err error
 
-		if inTransaction {
-			sqlResult, err = tx.ExecContext(ctx, stmt)
+		if class.Returning {
+			var db queryer = conn
+			if tx != nil {
+				db = tx
+			}
+			result.Returning, sqlResult, err = executeReturning(ctx, db, stmt.Text, args, opts)
+		} else if tx != nil {
+			sqlResult, err = tx.ExecContext(ctx, stmt.Text, args...)
 		} else {
-			sqlResult, err = db.ExecContext(ctx, stmt)
+			sqlResult, err = conn.ExecContext(ctx, stmt.Text, args...)
 		}
 
 		if err != nil {
 			result.Error = err
-			results = append(results, result)
+			results = append(results, result.finish(started))
 
-			if inTransaction {
+			// セーブポイントの場合はドライラン全体のロールバックで取り消される
+			if tx != nil {
 				tx.Rollback()
 				inTransaction = false
 				tx = nil
 			}
 
-			return results, fmt.Errorf("failed to execute statement %d: %w", i+1, err)
+			return results, fmt.Errorf("failed to execute statement %d (line %d, offset %d): %w", i+1, stmt.Line, stmt.Offset, err)
 		}
 
 		// 結果の処理
 		rowsAffected, _ := sqlResult.RowsAffected()
 		result.RowsAffected = rowsAffected
 
-		if operationType == "INSERT" {
+		if class.IsInsert() {
 			lastInsertID, _ := sqlResult.LastInsertId()
 			result.LastInsertID = lastInsertID
 		}
 
 		result.Success = true
-		results = append(results, result)
+		results = append(results, result.finish(started))
+
+		// 危険なステートメントの検出（実行結果で分かるもの）
+		if opts.risks != nil {
+			opts.risks.checkResult(i, stmt, result)
+		}
 	}
 
 	// トランザクションが閉じられていない場合
@@ -173,6 +335,62 @@ func executeStatements(ctx context.Context, db *sql.DB, stmts []string) ([]state
 	return results, nil
 }
 
+// returningResult - RETURNING 句のあるステートメントの sql.Result
+type returningResult struct {
+	rowsAffected int64
+	lastInsertID int64
+}
+
+func (r returningResult) LastInsertId() (int64, error) { return r.lastInsertID, nil }
+func (r returningResult) RowsAffected() (int64, error) { return r.rowsAffected, nil }
+
+// executeReturning - RETURNING 句のあるステートメントを実行し、返された行をすべて読む
+// 行を読み切らないとステートメントが最後まで実行されないため、上限を超えた行も読んでから捨てる
+func executeReturning(ctx context.Context, db queryer, stmt string, args []interface{}, opts executeOptions) (*returnedRows, sql.Result, error) {
+	rows, err := db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+	returned := &returnedRows{Columns: columns, Rows: [][]interface{}{}}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range columns {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, nil, err
+		}
+		if opts.maxReturnedRows > 0 && len(returned.Rows) >= opts.maxReturnedRows {
+			returned.Truncated = true
+			continue
+		}
+		if opts.encoder != nil {
+			for i, val := range values {
+				values[i] = opts.encoder.encode(val)
+			}
+		}
+		returned.Rows = append(returned.Rows, values)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	rows.Close()
+
+	// 変更行数と最後の ROWID は同じ接続で SQLite に問い合わせる
+	var result returningResult
+	if err := db.QueryRowContext(ctx, "SELECT changes(), last_insert_rowid()").Scan(&result.rowsAffected, &result.lastInsertID); err != nil {
+		return nil, nil, err
+	}
+	return returned, result, nil
+}
+
 // formatResponse - 実行結果をフォーマット
 func formatResponse(results []statementResult) string {
 	totalStatements := len(results)
@@ -185,7 +403,7 @@ func formatResponse(results []statementResult) string {
 			successfulStatements++
 			totalRowsAffected += result.RowsAffected
 
-			if result.Operation == "INSERT" && result.LastInsertID > 0 {
+			if (result.Operation == "INSERT" || result.Operation == "REPLACE") && result.LastInsertID > 0 {
 				lastInsertID = result.LastInsertID
 			}
 		}
@@ -210,7 +428,7 @@ func formatResponse(results []statementResult) string {
 		// エラー情報を追加
 		for i, result := range results {
 			if !result.Success {
-				response += fmt.Sprintf("\nError in statement %d: %s", i+1, result.Error)
+				response += fmt.Sprintf("\nError in statement %d (line %d, offset %d): %s", i+1, result.Line, result.Offset, result.Error)
 			}
 		}
 	}
@@ -218,53 +436,218 @@ func formatResponse(results []statementResult) string {
 	return response
 }
 
+// writeQueryResult - 実行結果を JSON テキストと structuredContent の両方で返す
+// スクリプトがエラーで中断した場合は、それまでの結果とエラーをエラー結果として返す
+func writeQueryResult(results []statementResult, total int, execErr error) *mcp.CallToolResult {
+	body := writeResult{Summary: formatResponse(results), Statements: statementOutcomes(results)}
+	if execErr != nil {
+		succeeded := 0
+		for _, result := range results {
+			if result.Success {
+				succeeded++
+			}
+		}
+		body.Summary = fmt.Sprintf("%d of %d statements succeeded before the script stopped", succeeded, total)
+		body.Error = execErr.Error()
+	}
+
+	result, err := jsonToolResult(body)
+	if err != nil {
+		zap.S().Errorw("failed to convert result to JSON", "error", err)
+		return mcp.NewToolResultError(err.Error())
+	}
+	result.IsError = execErr != nil
+	result.StructuredContent = body
+	return result
+}
+
 // RegisterWriteQueryTool - Register the write_query tool
-func RegisterWriteQueryTool(mcpServer *server.MCPServer, db *sql.DB) error {
+func RegisterWriteQueryTool(mcpServer *server.MCPServer, databases *Databases, cfg *config.Config) error {
 	zap.S().Debug("registering write_query tool")
 
+	if cfg.Confirmation.Enabled && cfg.Confirmation.TokenTTL <= 0 {
+		return fmt.Errorf("confirmation.token_ttl must be positive, got %s", cfg.Confirmation.TokenTTL)
+	}
+
+	encoder, err := newValueEncoder(cfg)
+	if err != nil {
+		return err
+	}
+
 	// Define the tool
 	tool := mcp.NewTool("write_query",
-		mcp.WithDescription("Execute write queries (INSERT, UPDATE, DELETE) to modify data in the database. Supports multiple statements separated by semicolons and transactions (BEGIN, COMMIT, ROLLBACK)"),
+		mcp.WithDescription("Execute write queries (INSERT, REPLACE, UPDATE, DELETE, including WITH ... forms) to modify data in the database. Supports multiple statements separated by semicolons and transactions (BEGIN, COMMIT, ROLLBACK). "+
+			"Returns one result per statement with rows affected, last insert ID, duration and error; a RETURNING clause adds the rows it returned"),
 		mcp.WithString("query",
-			mcp.Description("The SQL write query (INSERT, UPDATE, DELETE) to execute"),
+			mcp.Description("The SQL write query (INSERT, REPLACE, UPDATE, DELETE) to execute"),
 			mcp.Required(),
 		),
+		mcp.WithAny("params",
+			mcp.Description(paramsDescription+". Positional values are consumed by the statements in order; named values are shared by all statements"),
+		),
+		mcp.WithNumber("timeout_ms",
+			mcp.Description(timeoutDescription+". The timeout covers the whole script; an open transaction is rolled back"),
+			mcp.Min(1),
+		),
+		mcp.WithBoolean("dry_run",
+			mcp.Description(fmt.Sprintf("Run the statements, report the rows affected per statement and up to %d changed rows per table before and after the change, then roll everything back", dryRunSampleRows)),
+		),
+		mcp.WithString("confirmation_token",
+			mcp.Description("Token returned when a destructive script was held back for confirmation. Repeat the same call with it to execute the script"),
+		),
+		databaseArgument(databases),
+		transactionArgument(),
 	)
 
 	// Add the tool handler
 	mcpServer.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		// Extract query parameter
-		query, ok := request.Params.Arguments["query"].(string)
+		query, ok := request.GetArguments()["query"].(string)
 		if !ok || query == "" {
 			return mcp.NewToolResultError("query parameter is required"), nil
 		}
 
-		zap.S().Debugw("executing write_query", "query", query)
+		rawParams := request.GetArguments()["params"]
+		params, err := parseQueryParams(rawParams)
+		if err != nil {
+			zap.S().Warnw("invalid params for write_query", "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		handle := request.GetString("transaction", "")
+		dryRun := request.GetBool("dry_run", false)
+		database, err := callDatabase(databases, request.GetString("database", ""), handle, true)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		// A confirmation token only runs the exact call it was issued for
+		token := request.GetString("confirmation_token", "")
+		key, err := confirmationKey("write_query", database.Name, handle, query, rawParams)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if token != "" {
+			if dryRun {
+				return mcp.NewToolResultError("confirmation_token cannot be combined with dry_run"), nil
+			}
+			if err := databases.confirmations.redeem(token, key, sessionIDFromContext(ctx)); err != nil {
+				zap.S().Warnw("rejected confirmation token", "error", err)
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			zap.S().Infow("write_query confirmed", "database", database.Name)
+		}
+
+		timeout, err := callTimeout(cfg, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx, cancel := withCallTimeout(ctx, timeout)
+		defer cancel()
+
+		zap.S().Debugw("executing write_query", "query", query, "transaction", handle, "dry_run", dryRun, "timeout", timeout)
 
 		// Split query into multiple statements
-		statements := splitQueries(query)
+		statements := splitStatements(query)
 		if len(statements) == 0 {
 			zap.S().Warnw("empty query", "query", query)
 			return mcp.NewToolResultError("empty query"), nil
 		}
 
-		// Execute statements
-		zap.S().Debugw("executing statements", "count", len(statements))
-		results, err := executeStatements(ctx, db, statements)
+		conn, release, err := callConn(ctx, databases, database, handle)
 		if err != nil {
-			zap.S().Errorw("failed to execute statements",
-				"error", err)
+			zap.S().Errorw("failed to get database connection", "error", err)
 			return mcp.NewToolResultError(err.Error()), nil
 		}
+		defer release()
+
+		opts := executeOptions{encoder: encoder, maxReturnedRows: cfg.Query.MaxRows}
+		if handle != "" {
+			opts.noTransactionControl = "cannot be used inside a transaction handle; use commit_transaction, rollback_transaction or savepoint"
+		}
+
+		// A dry run executes inside a savepoint that is always rolled back, also within a transaction handle
+		if dryRun {
+			zap.S().Debugw("executing dry run", "count", len(statements))
+			results, tables, err := executeDryRun(ctx, conn, database, statements, params, opts, encoder)
+			if err != nil {
+				zap.S().Errorw("failed to execute dry run", "error", err)
+				return errorResult(ctx, timeout, fmt.Errorf("%w (dry run, nothing was changed)", err)), nil
+			}
+			zap.S().Infow("dry run executed",
+				"total", len(statements),
+				"tables", len(tables))
+
+			result, err := jsonToolResult(newDryRunResult(results, tables))
+			if err != nil {
+				zap.S().Errorw("failed to convert result to JSON", "error", err)
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			return result, nil
+		}
+
+		// A single DELETE within the row limit runs right away; its rows are counted before it runs
+		if cfg.Confirmation.Enabled && token == "" && countsDeleteOnly(statements, cfg.Confirmation) {
+			guard := opts
+			guard.risks = &riskCollector{rules: cfg.Confirmation, authorizer: database.Authorizer}
+			results, err := executeStatements(ctx, conn, statements, params, guard)
+			if err != nil || len(guard.risks.risks) == 0 {
+				return executedResult(ctx, timeout, statements, results, err), nil
+			}
+
+			// Nothing ran, so there are no changes to preview
+			zap.S().Infow("write_query held back for confirmation",
+				"database", database.Name,
+				"risks", len(guard.risks.risks))
+			return holdForConfirmation(ctx, databases, cfg.Confirmation, key, guard.risks.risks, newDryRunResult(results, []tableChanges{})), nil
+		}
+
+		// Destructive scripts are dry-run first and held back until the call is repeated with a token
+		if cfg.Confirmation.Enabled && token == "" && mayNeedConfirmation(statements, cfg.Confirmation) {
+			guard := opts
+			guard.risks = &riskCollector{rules: cfg.Confirmation, authorizer: database.Authorizer}
+			results, tables, err := executeDryRun(ctx, conn, database, statements, params, guard, encoder)
+			if err != nil {
+				zap.S().Errorw("failed to check statements for confirmation", "error", err)
+				return errorResult(ctx, timeout, err), nil
+			}
 
-		// Format response
-		responseMessage := formatResponse(results)
-		zap.S().Infow("statements executed",
-			"total", len(statements),
-			"successful", len(results))
+			if len(guard.risks.risks) > 0 {
+				zap.S().Infow("write_query held back for confirmation",
+					"database", database.Name,
+					"risks", len(guard.risks.risks))
+				return holdForConfirmation(ctx, databases, cfg.Confirmation, key, guard.risks.risks, newDryRunResult(results, tables)), nil
+			}
+
+			// The dry run consumed the positional params
+			if params, err = parseQueryParams(rawParams); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
 
-		return mcp.NewToolResultText(responseMessage), nil
+		// Execute statements
+		zap.S().Debugw("executing statements", "count", len(statements))
+		results, err := executeStatements(ctx, conn, statements, params, opts)
+		return executedResult(ctx, timeout, statements, results, err), nil
 	})
 
 	return nil
 }
+
+// executedResult - Tool result of statements that were executed for real
+func executedResult(ctx context.Context, timeout time.Duration, statements []sqlStatement, results []statementResult, err error) *mcp.CallToolResult {
+	if err != nil {
+		zap.S().Errorw("failed to execute statements",
+			"error", err)
+		if result := interruptedResult(ctx, timeout, err); result != nil {
+			return result
+		}
+		return writeQueryResult(results, len(statements), err)
+	}
+
+	zap.S().Infow("statements executed",
+		"total", len(statements),
+		"successful", len(results))
+
+	return writeQueryResult(results, len(statements), nil)
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/cnosuke/mcp-sqlite/config"
+	"github.com/cockroachdb/errors"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// Supported transport types
+const (
+	TransportStdio          = "stdio"
+	TransportSSE            = "sse"
+	TransportStreamableHTTP = "streamable-http"
+	TransportHTTP           = "http"
+)
+
+const unixSocketPrefix = "unix:"
+
+// serve - Serve the MCP server with the configured transport until it is terminated
+func serve(mcpServer *server.MCPServer, cfg *config.Config) error {
+	switch cfg.Transport.Type {
+	case TransportStdio, "":
+		zap.S().Info("starting MCP server with stdio transport")
+		return server.ServeStdio(mcpServer)
+	case TransportSSE, TransportStreamableHTTP, TransportHTTP:
+		return serveHTTP(mcpServer, cfg)
+	default:
+		return errors.Newf("unsupported transport type: %s", cfg.Transport.Type)
+	}
+}
+
+// serveHTTP - Serve the MCP server over SSE and/or Streamable HTTP and shut down gracefully on SIGINT/SIGTERM
+func serveHTTP(mcpServer *server.MCPServer, cfg *config.Config) error {
+	transportType := cfg.Transport.Type
+	httpServer := &http.Server{}
+	mux := http.NewServeMux()
+	httpServer.Handler = mux
+
+	var sseServer *server.SSEServer
+	if transportType == TransportSSE || transportType == TransportHTTP {
+		sseServer = server.NewSSEServer(mcpServer,
+			server.WithHTTPServer(httpServer),
+			server.WithBaseURL(cfg.Transport.BaseURL),
+			server.WithSSEEndpoint(cfg.Transport.SSEPath),
+			server.WithMessageEndpoint(cfg.Transport.MessagePath),
+		)
+		mux.Handle(cfg.Transport.SSEPath, sseServer.SSEHandler())
+		mux.Handle(cfg.Transport.MessagePath, sseServer.MessageHandler())
+	}
+
+	var streamableServer *server.StreamableHTTPServer
+	if transportType == TransportStreamableHTTP || transportType == TransportHTTP {
+		streamableServer = server.NewStreamableHTTPServer(mcpServer,
+			server.WithEndpointPath(cfg.Transport.StreamablePath),
+		)
+		mux.Handle(cfg.Transport.StreamablePath, streamableServer)
+	}
+
+	listener, err := listen(cfg.Transport.Listen)
+	if err != nil {
+		zap.S().Errorw("failed to listen",
+			"listen", cfg.Transport.Listen,
+			"error", err)
+		return err
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		zap.S().Infow("starting MCP server with HTTP transport",
+			"transport", transportType,
+			"listen", cfg.Transport.Listen)
+		errCh <- httpServer.Serve(listener)
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(sigCh)
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return errors.Wrap(err, "HTTP server stopped unexpectedly")
+	case sig := <-sigCh:
+		zap.S().Infow("received signal, shutting down HTTP server", "signal", sig.String())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Transport.ShutdownTimeout)
+	defer cancel()
+
+	// The streamable server does not own the HTTP server, so this only stops its session sweeper.
+	if streamableServer != nil {
+		if err := streamableServer.Shutdown(ctx); err != nil {
+			zap.S().Warnw("failed to shut down streamable HTTP server", "error", err)
+		}
+	}
+
+	// Closing SSE sessions first lets long-lived event streams finish so Shutdown does not wait for them.
+	if sseServer != nil {
+		err = sseServer.Shutdown(ctx)
+	} else {
+		err = httpServer.Shutdown(ctx)
+	}
+	if err != nil {
+		zap.S().Warnw("graceful shutdown did not complete, closing remaining connections", "error", err)
+		if closeErr := httpServer.Close(); closeErr != nil {
+			return errors.Wrap(closeErr, "failed to close HTTP server")
+		}
+	}
+
+	return nil
+}
+
+// listen - Open a TCP or Unix socket listener for the given address
+func listen(address string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(address, unixSocketPrefix); ok {
+		// Remove a stale socket left behind by a previous run
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(path); err != nil {
+				return nil, errors.Wrap(err, "failed to remove existing unix socket")
+			}
+		}
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to listen on unix socket")
+		}
+		return listener, nil
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to listen on TCP address")
+	}
+	return listener, nil
+}